@@ -8,6 +8,7 @@ import (
 type Config struct {
 	core.Config
 	smarter.CertPem
+	WsOrigin []string `json:",optional"` //websocket允许的跨域Origin
 }
 
 func (t Config) Setup() func() {
@@ -34,14 +34,21 @@ func dbInit(conf smarter.Server) {
 }
 
 func initRpc(conf config.Config) {
-	conf.Add( //注册rpc节点
+	conf.Add(transfer.DispatchServices()...) //注册静态映射的rpc节点
 
-	)
+	smarter.WatchRoutes() //app与rpc节点映射以etcd路由表为准, 变更即时生效
+
+	smarter.WatchIdempotent() //各服务发布的幂等方法, 决定网关是否自动重试
+
+	smarter.InitWs(conf.Redis.Db, conf.WsOrigin...) //websocket推送, 经redis在网关实例间扇出
 }
 
 func initApi(conf config.Config) {
 
 	server = conf.Gin.NewServer(release)
+	smarter.WatchGinCert(server)
+
+	smarter.GinAuth = core.CertPem.Authenticator() //凭证由CertPem.AuthToken签发
 
 	server.Reg(router.Init)
 }
@@ -10,7 +10,7 @@ func main() {
 	conf := config.Init(core.ConfigFile)
 	defer Init(conf)()
 
-	server.Run()
+	go server.Run()
 
-	smarter.TEARDOWN()
+	smarter.TEARDOWN() //收到信号后先摘除健康检查, 再等待进行中的请求
 }
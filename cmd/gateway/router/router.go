@@ -1,6 +1,7 @@
 package router
 
 import (
+	"mykit/core/smarter"
 	"mykit/core/transfer"
 
 	"github.com/gin-gonic/gin"
@@ -9,12 +10,59 @@ import (
 func Init(e *gin.Engine) {
 	transfer.RegRouter(e,
 		apiGroup,
+		docGroup,
+		batchGroup,
+		rbacGroup,
+		streamGroup,
+		sseGroup,
+		routeGroup,
+		wsGroup,
+		healthGroup,
 	)
 }
 
 func apiGroup(e *gin.Engine) {
-	group := e.Group("/api/:app/:method")
+	group := e.Group("/api/:app/:method", smarter.RbacMiddleware())
 	{
-		group.POST("", nil)
+		group.POST("", smarter.GinHandler2())
 	}
 }
+
+func docGroup(e *gin.Engine) {
+	e.GET("/api/openapi", transfer.OnlyLocal(), smarter.OpenApiHandler())
+	e.GET("/api/schema/:app/:method", transfer.OnlyLocal(), smarter.JsonSchemaHandler())
+}
+
+func batchGroup(e *gin.Engine) {
+	e.POST("/api/batch", smarter.BatchHandler())
+}
+
+func rbacGroup(e *gin.Engine) {
+	e.POST("/api/rbac/can", smarter.AuthMiddleware(), smarter.CanHandler())
+}
+
+func streamGroup(e *gin.Engine) {
+	e.POST("/stream/:app/:method", smarter.AuthMiddleware(), smarter.RbacMiddleware(), smarter.StreamHandler())
+}
+
+func sseGroup(e *gin.Engine) {
+	group := e.Group("/sse/:app/:method", smarter.AuthMiddleware(), smarter.RbacMiddleware())
+	{
+		group.GET("", smarter.SseHandler())
+		group.POST("", smarter.SseHandler())
+	}
+}
+
+func routeGroup(e *gin.Engine) {
+	e.GET("/api/routes", transfer.OnlyLocal(), smarter.RouteHandler())
+	e.GET("/api/breakers", transfer.OnlyLocal(), smarter.BreakerHandler())
+}
+
+func wsGroup(e *gin.Engine) {
+	e.GET("/ws", smarter.WsAuthMiddleware(), smarter.WsHandler())
+	e.GET("/api/ws/stat", transfer.OnlyLocal(), smarter.WsStatHandler())
+}
+
+func healthGroup(e *gin.Engine) {
+	e.GET("/health", transfer.GinHeartbeat)
+}
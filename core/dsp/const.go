@@ -7,6 +7,7 @@ import (
 const (
 	StatusStopped = -999
 	StatusInited  = 1000
+	StatusDrain   = -1 //退出中, 健康检查返回未就绪
 )
 
 const (
@@ -40,14 +41,20 @@ const (
 	RspCodeSuccess   = http.StatusOK
 	RspCodeMsg       = http.StatusPartialContent
 	RspCodeForbidden = http.StatusForbidden
+	RspCodeBreaker   = http.StatusServiceUnavailable
+	RspCodeConflict  = http.StatusConflict
+	RspCodeProgress  = http.StatusProcessing
+	RspCodeStreamEnd = http.StatusNoContent
 )
 
 const (
 	CodeUnimplemented    = -12
 	CodeInvalidArgument  = -3
 	CodeDeadlineExceeded = -4
+	CodeFailedPrecond    = -9
 	CodeFailedOnRequired = -36
 	CodeInternal         = -13
+	CodeUnavailable      = -14
 )
 
 const (
@@ -70,4 +77,9 @@ const (
 	TagUid      = "uid"
 	TagUserInst = "userInst"
 	TagLanguage = "language"
+	TagCodec    = "codec"
+	TagTimeout  = "lpc-timeout"
+	TagRole     = "role"
+	TagSign     = "lpc-sign"
+	TagSignTs   = "lpc-sign-ts"
 )
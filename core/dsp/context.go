@@ -3,6 +3,7 @@ package dsp
 import (
 	"context"
 	. "mykit/core/types"
+	"strings"
 	"time"
 
 	oteltrace "go.opentelemetry.io/otel/trace"
@@ -165,6 +166,28 @@ func GetUser(ctx context.Context) string {
 	return GetStringFromContext(ctx, TagUser)
 }
 
+// GetRole 多个角色以逗号分隔
+func GetRole(ctx context.Context) []string {
+	role := GetStringFromContext(ctx, TagRole)
+	if role == "" {
+		return nil
+	}
+
+	return strings.Split(role, ",")
+}
+
+func HasRole(ctx context.Context, role ...string) bool {
+	for _, v := range GetRole(ctx) {
+		for _, v2 := range role {
+			if v == v2 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func GetSession(ctx context.Context) string {
 	return GetStringFromContext(ctx, TagSession)
 }
@@ -0,0 +1,288 @@
+package dsp
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	. "mykit/core/types"
+	"reflect"
+	"sync"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	CodecJson    = "json"
+	CodecProto   = "proto"
+	CodecMsgpack = "msgpack"
+	CodecGob     = "gob"
+)
+
+var (
+	encoderLock sync.RWMutex
+	encoders    = map[string]NamedEncoder{}
+)
+
+func init() {
+	RegEncoder(
+		NewJsonEncoder(),
+		NewProtoEncoder(),
+		NewMsgpackEncoder(),
+		NewGobEncoder(),
+	)
+}
+
+func RegEncoder(e ...NamedEncoder) {
+	encoderLock.Lock()
+	for _, v := range e {
+		encoders[v.Name()] = v
+	}
+	encoderLock.Unlock()
+}
+
+// GetEncoder 未注册或为空时返回json
+func GetEncoder(name string) Encoder {
+	encoderLock.RLock()
+	res, ok := encoders[name]
+	if !ok {
+		res = encoders[CodecJson]
+	}
+	encoderLock.RUnlock()
+
+	return res
+}
+
+// EncoderName 未实现NamedEncoder时为空
+func EncoderName(e Encoder) string {
+	v, ok := e.(NamedEncoder)
+	if !ok {
+		return ""
+	}
+
+	return v.Name()
+}
+
+func HasEncoder(name string) bool {
+	encoderLock.RLock()
+	_, ok := encoders[name]
+	encoderLock.RUnlock()
+
+	return ok
+}
+
+func IsJsonEncoder(e Encoder) bool {
+	return e == nil || EncoderName(e) == CodecJson
+}
+
+// Transcoder 可与json互转的编码, 供网关转换给浏览器
+type Transcoder interface {
+	ToJson([]byte) ([]byte, error)
+	FromJson([]byte) ([]byte, error)
+}
+
+type encoderTypes struct {
+	l *sync.RWMutex
+	m map[string]reflect.Type
+}
+
+func newEncoderTypes() encoderTypes {
+	res := encoderTypes{
+		l: &sync.RWMutex{},
+		m: map[string]reflect.Type{},
+	}
+
+	return res
+}
+
+func (t encoderTypes) reg(k string, obj interface{}) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	_, ok := t.m[k]
+	if ok {
+		return ErrAlreadyExist
+	}
+
+	t.m[k] = reflect.TypeOf(obj)
+
+	return nil
+}
+
+func (t encoderTypes) load(k string, raw []byte, decode func([]byte, interface{}) error) (
+	obj interface{}, err error) {
+	t.l.RLock()
+	m, ok := t.m[k]
+	t.l.RUnlock()
+
+	if !ok {
+		return raw, nil
+	}
+
+	obj = reflect.New(m).Interface()
+	err = decode(raw, obj)
+
+	return
+}
+
+type ProtoEncoder struct {
+	encoderTypes
+}
+
+func NewProtoEncoder() *ProtoEncoder {
+	res := &ProtoEncoder{
+		encoderTypes: newEncoderTypes(),
+	}
+
+	return res
+}
+
+func (t *ProtoEncoder) Name() string {
+	return CodecProto
+}
+
+func (t *ProtoEncoder) Valid(raw []byte) bool {
+	return true
+}
+
+func (t *ProtoEncoder) Encode(raw interface{}) (res []byte, err error) {
+	if raw == nil {
+		return []byte{}, nil
+	}
+
+	m, ok := raw.(proto.Message)
+	if !ok {
+		return nil, ErrInvalidParam
+	}
+
+	return proto.Marshal(m)
+}
+
+func (t *ProtoEncoder) Decode(raw []byte, obj interface{}) (err error) {
+	m, ok := obj.(proto.Message)
+	if !ok {
+		return ErrInvalidParam
+	}
+
+	return proto.Unmarshal(raw, m)
+}
+
+func (t *ProtoEncoder) Reg(k string, obj interface{}) error {
+	return t.reg(k, obj)
+}
+
+func (t *ProtoEncoder) Load(k string, raw []byte) (obj interface{}, err error) {
+	return t.load(k, raw, t.Decode)
+}
+
+type MsgpackEncoder struct {
+	encoderTypes
+	h *codec.MsgpackHandle
+}
+
+func NewMsgpackEncoder() *MsgpackEncoder {
+	h := &codec.MsgpackHandle{
+		WriteExt: true,
+	}
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+
+	res := &MsgpackEncoder{
+		encoderTypes: newEncoderTypes(),
+		h:            h,
+	}
+
+	return res
+}
+
+func (t *MsgpackEncoder) Name() string {
+	return CodecMsgpack
+}
+
+func (t *MsgpackEncoder) Valid(raw []byte) bool {
+	var v interface{}
+	return t.Decode(raw, &v) == nil
+}
+
+func (t *MsgpackEncoder) Encode(raw interface{}) (res []byte, err error) {
+	err = codec.NewEncoderBytes(&res, t.h).Encode(raw)
+	return
+}
+
+func (t *MsgpackEncoder) Decode(raw []byte, obj interface{}) (err error) {
+	return codec.NewDecoderBytes(raw, t.h).Decode(obj)
+}
+
+func (t *MsgpackEncoder) Reg(k string, obj interface{}) error {
+	return t.reg(k, obj)
+}
+
+func (t *MsgpackEncoder) Load(k string, raw []byte) (obj interface{}, err error) {
+	return t.load(k, raw, t.Decode)
+}
+
+func (t *MsgpackEncoder) ToJson(raw []byte) ([]byte, error) {
+	if len(raw) == 0 {
+		return ByteOfNullJson, nil
+	}
+
+	var v interface{}
+	err := t.Decode(raw, &v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+func (t *MsgpackEncoder) FromJson(raw []byte) ([]byte, error) {
+	var v interface{}
+	err := json.Unmarshal(EnsureJsonByte(raw), &v)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Encode(v)
+}
+
+type GobEncoder struct {
+	encoderTypes
+}
+
+func NewGobEncoder() *GobEncoder {
+	res := &GobEncoder{
+		encoderTypes: newEncoderTypes(),
+	}
+
+	return res
+}
+
+func (t *GobEncoder) Name() string {
+	return CodecGob
+}
+
+func (t *GobEncoder) Valid(raw []byte) bool {
+	return len(raw) > 0
+}
+
+func (t *GobEncoder) Encode(raw interface{}) (res []byte, err error) {
+	var buf bytes.Buffer
+	err = gob.NewEncoder(&buf).Encode(raw)
+	if err != nil {
+		return
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (t *GobEncoder) Decode(raw []byte, obj interface{}) (err error) {
+	return gob.NewDecoder(bytes.NewReader(raw)).Decode(obj)
+}
+
+func (t *GobEncoder) Reg(k string, obj interface{}) error {
+	return t.reg(k, obj)
+}
+
+func (t *GobEncoder) Load(k string, raw []byte) (obj interface{}, err error) {
+	return t.load(k, raw, t.Decode)
+}
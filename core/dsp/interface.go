@@ -162,6 +162,12 @@ type Encoder interface {
 	Load(string, []byte) (interface{}, error)
 }
 
+// NamedEncoder 可按名称注册的Encoder
+type NamedEncoder interface {
+	Encoder
+	Name() string
+}
+
 type KVCache interface {
 	Put(k string, v []byte)
 	Get(k string) []byte
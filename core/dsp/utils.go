@@ -13,7 +13,6 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
-	"sync"
 	"sync/atomic"
 	"text/template"
 	"time"
@@ -114,18 +113,21 @@ func (t *Spokesman) DoSpeech(ctx context.Context) string {
 }
 
 type JsonEncoder struct {
-	l sync.RWMutex
-	m map[string]reflect.Type
+	encoderTypes
 }
 
 func NewJsonEncoder() *JsonEncoder {
 	res := &JsonEncoder{
-		m: map[string]reflect.Type{},
+		encoderTypes: newEncoderTypes(),
 	}
 
 	return res
 }
 
+func (t *JsonEncoder) Name() string {
+	return CodecJson
+}
+
 func (t *JsonEncoder) Valid(raw []byte) bool {
 	return json.Valid(raw)
 }
@@ -139,36 +141,11 @@ func (t *JsonEncoder) Decode(raw []byte, obj interface{}) (err error) {
 }
 
 func (t *JsonEncoder) Reg(k string, obj interface{}) error {
-	t.l.RLock()
-	_, ok := t.m[k]
-	t.l.RUnlock()
-
-	if ok {
-		return ErrAlreadyExist
-	}
-
-	m := reflect.TypeOf(obj)
-
-	t.l.Lock()
-	t.m[k] = m
-	t.l.Unlock()
-
-	return nil
+	return t.reg(k, obj)
 }
 
 func (t *JsonEncoder) Load(k string, raw []byte) (obj interface{}, err error) {
-	t.l.RLock()
-	m, ok := t.m[k]
-	t.l.RUnlock()
-
-	if !ok {
-		return raw, nil
-	}
-
-	obj = reflect.New(m).Interface()
-	err = t.Decode(raw, obj)
-
-	return
+	return t.load(k, raw, t.Decode)
 }
 
 type FnvSelector struct {
@@ -73,6 +73,23 @@ func initLog(raw LogConfig) {
 	tracking = zap.New(core).With(f...)
 }
 
+// UseLogCore 以指定core替换全局logger并返回恢复函数, 供测试捕获日志
+func UseLogCore(core zapcore.Core) (restore func()) {
+	logInitOnce.Do(func() {})
+
+	z, l, tr := zLogger, LogS1, tracking
+
+	zLogger = zap.New(core)
+	LogS1 = Logger(Ctx).Skip(1)
+	tracking = zap.New(core)
+
+	restore = func() {
+		zLogger, LogS1, tracking = z, l, tr
+	}
+
+	return
+}
+
 func SyncLog() {
 	zLogger.Sync()
 	LogS1.Sync()
@@ -37,8 +37,26 @@ var (
 	GlobalContext context.Context
 	GlobalCancel  context.CancelFunc
 	TeardownJobs  = []func(){}
+
+	// DrainJobs 收到退出信号后、GlobalCancel之前并发执行, 用于停止接入并等待进行中的请求
+	DrainJobs = []func(ctx context.Context){}
+	drainLock sync.Mutex
 )
 
+// AddDrainJob server在开始监听时注册, 与退出信号并发
+func AddDrainJob(f ...func(ctx context.Context)) {
+	drainLock.Lock()
+	DrainJobs = append(DrainJobs, f...)
+	drainLock.Unlock()
+}
+
+func GetDrainJobs() []func(ctx context.Context) {
+	drainLock.Lock()
+	defer drainLock.Unlock()
+
+	return append([]func(ctx context.Context){}, DrainJobs...)
+}
+
 func BatchSet(raw ...string) {
 	l := len(raw)
 
@@ -253,6 +253,26 @@ func (t *EtcdContext) Watch(k string, h ...EtcdEventHandler) {
 	}
 }
 
+// WatchEvent 同Watch, 删除事件交给del处理
+func (t *EtcdContext) WatchEvent(k string, put, del EtcdEventHandler) {
+	c := t.Client.Watch(context.Background(), k, clientv3.WithPrefix())
+
+	for v := range c {
+		for _, v2 := range v.Events {
+			if v2.Kv == nil {
+				continue
+			}
+
+			switch v2.Type {
+			case EtcdEventPut:
+				put(v2.Kv)
+			case EtcdEventDELETE:
+				del(v2.Kv)
+			}
+		}
+	}
+}
+
 func DefaultEtcdEventHandler(raw *mvccpb.KeyValue) {
 	log.Printf("recv etcd event: %v\n", raw)
 }
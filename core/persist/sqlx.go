@@ -31,10 +31,11 @@ func SetSqlxDebug(raw ...bool) {
 	sqlxDebugSwitch = ParseBool(raw)
 }
 
+// SqlInterface 均使用ctx版本, 调用方取消或超时后查询随之中断
 type SqlInterface interface {
-	Get(dest interface{}, query string, args ...interface{}) error
-	Select(dest interface{}, query string, args ...interface{}) error
-	NamedExec(query string, arg interface{}) (sql.Result, error)
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
@@ -459,7 +459,7 @@ func (t *SqlxContext) insertManyAndGetResult(k int, dataList interface{}, ig ...
 	*/
 
 	t0 := time.Now()
-	result, err = t.Db().NamedExec(sqlStr, dataList)
+	result, err = t.Db().NamedExecContext(t.Ctx(), sqlStr, dataList)
 	cost = time.Now().Sub(t0)
 
 	if err != nil {
@@ -478,7 +478,7 @@ func (t *SqlxContext) getRow(k int, data interface{}, sqlStr string, args ...int
 	k++
 
 	t0 := time.Now()
-	err = t.Db().Get(data, sqlStr, args...)
+	err = t.Db().GetContext(t.Ctx(), data, sqlStr, args...)
 	cost := time.Now().Sub(t0)
 
 	defer func() {
@@ -545,7 +545,7 @@ func (t *SqlxContext) getList(k int, dataList interface{}, sqlStr string, raw ..
 	}
 
 	t0 := time.Now()
-	err = t.Db().Select(dataList, sqlStr, args...)
+	err = t.Db().SelectContext(t.Ctx(), dataList, sqlStr, args...)
 	cost := time.Now().Sub(t0)
 
 	l = len(args)
@@ -577,7 +577,7 @@ func (t *SqlxContext) namedExec(k int, event, sqlStr string, uMap DbContext) (re
 	uMap = uMap.FilterBlackFiled()
 
 	t0 := time.Now()
-	result, err = t.Db().NamedExec(sqlStr, uMap)
+	result, err = t.Db().NamedExecContext(t.Ctx(), sqlStr, uMap)
 	cost := time.Now().Sub(t0)
 
 	var rowsAffected int64 = -2
@@ -27,8 +27,14 @@ const (
 var (
 	etcdTenantConfig    = "config"
 	etcdTenantConfigEnv = etcdTenantConfig + EtcdDelimiter + "env"
+	etcdTenantConfigLpc = etcdTenantConfig + EtcdDelimiter + "lpc"
 
 	etcdTenantMeta = "meta"
+	etcdTenantSign = etcdTenantConfig + EtcdDelimiter + "sign"
+
+	etcdTenantRoute = etcdTenantConfig + EtcdDelimiter + "route"
+
+	etcdTenantIdempotent = etcdTenantConfig + EtcdDelimiter + "idempotent"
 )
 
 func initTenantEtcd(root string) {
@@ -36,7 +42,11 @@ func initTenantEtcd(root string) {
 
 	AddPrefix(root,
 		&etcdTenantConfigEnv,
+		&etcdTenantConfigLpc,
 		&etcdTenantMeta,
+		&etcdTenantSign,
+		&etcdTenantRoute,
+		&etcdTenantIdempotent,
 	)
 }
 
@@ -88,6 +88,17 @@ func (t Server) RunRpc(raw ...SmarterHandler) {
 	rpc.MaxMsgSize = DeIntParam(rpc.MaxMsgSize, t.MaxMsgSize)
 	rpc.Etcd = t.ETCD(rpc.Etcd)
 
+	//重试由调用方决定, 幂等标记须发布到etcd
+	if len(Idempotents()) > 0 {
+		err := PublishIdempotent()
+		if err != nil {
+			LogS1.Warn(LogMsgSetup,
+				LogEvent("idempotent"),
+				LogError(err),
+			)
+		}
+	}
+
 	rpc.RunRpc(raw...)
 }
 
@@ -95,6 +106,10 @@ func (t Server) Add(app ...string) {
 	Router.Add(t.Etcd, app...)
 }
 
+func (t Server) Shadow(conf ShadowConfig, app ...string) {
+	Router.Shadow(t.Etcd, conf, app...)
+}
+
 func (t Server) OpenMysql(raw ...MysqlConfig) *sqlx.DB {
 	conf := t.MYSQL(raw...)
 	conf.Db = conf.Db
@@ -7,6 +7,7 @@ import (
 	. "mykit/core/types"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 )
 
@@ -70,6 +71,10 @@ func TEARDOWN(sig ...os.Signal) {
 	<-handleSignal(sig...)
 	DevDebug("\nsignal.Notify")
 
+	DevDebug("DRAIN")
+	SetStatus(StatusDrain)
+	drain()
+
 	DevDebug("GlobalCancel")
 	GlobalCancel()
 	SetStatus(StatusStopped)
@@ -79,3 +84,18 @@ func TEARDOWN(sig ...os.Signal) {
 
 	DevDebug("End of TEARDOWN")
 }
+
+func drain() {
+	wg := sync.WaitGroup{}
+	for _, v := range GetDrainJobs() {
+		wg.Add(1)
+		go func(f func(ctx context.Context)) {
+			defer wg.Done()
+			defer Recover("drain")
+
+			f(Ctx)
+		}(v)
+	}
+
+	wg.Wait()
+}
@@ -1,8 +1,10 @@
 package smarter
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
@@ -10,6 +12,14 @@ import (
 	. "mykit/core/persist"
 	. "mykit/core/transfer"
 	. "mykit/core/types"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	authQuery = "token"
 )
 
 type EnvConfig struct {
@@ -94,6 +104,79 @@ func (t *CertPem) Decrypt(raw string) (res []byte, err error) {
 	return rsa.DecryptPKCS1v15(rand.Reader, t.privateKey, in)
 }
 
+// Sign 以私钥对待签串签名, 对应网关SignVerifier的RSA客户端
+func (t *CertPem) Sign(payload string) (string, error) {
+	return RsaSign(t.privateKey, payload)
+}
+
+// VerifySign 以公钥校验Sign的签名
+func (t *CertPem) VerifySign(payload, sign string) error {
+	raw, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.Sum256(StringToBytes(payload))
+
+	return rsa.VerifyPKCS1v15(t.publicKey, crypto.SHA256, h[:], raw)
+}
+
+// AuthToken 以私钥签名AuthInfo, 形如 base64url(json).签名; ttl为0时不过期
+func (t *CertPem) AuthToken(info AuthInfo, ttl time.Duration) string {
+	if t.privateKey == nil {
+		return ""
+	}
+
+	if ttl > 0 {
+		info.Exp = time.Now().Add(ttl).Unix()
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(MustJsonMarshal(info))
+
+	sign, err := t.Sign(payload)
+	if err != nil {
+		return ""
+	}
+
+	return payload + "." + sign
+}
+
+// Authenticator 以公钥校验AuthToken签发的凭证, 取自A-Token header
+func (t *CertPem) Authenticator() Authenticator {
+	var f = func(c *gin.Context) (res AuthInfo, err error) {
+		if t.publicKey == nil {
+			err = ErrInvalidParam
+			return
+		}
+
+		payload, sign, ok := strings.Cut(c.GetHeader(HeadAToken), ".")
+		if !ok {
+			err = ErrSignInvalid
+			return
+		}
+
+		err = t.VerifySign(payload, sign)
+		if err != nil {
+			err = ErrSignInvalid
+			return
+		}
+
+		raw, err := base64.RawURLEncoding.DecodeString(payload)
+		if err != nil {
+			return
+		}
+
+		err = UnmarshalJson(raw, &res)
+		if err == nil && res.Expired() {
+			err = ErrTokenExpired
+		}
+
+		return
+	}
+
+	return f
+}
+
 type TenantConfig struct {
 	Project     string `json:",optional"`
 	Tenant      string `json:",optional"`
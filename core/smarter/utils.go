@@ -19,6 +19,12 @@ import (
 
 var (
 	Router = SmarterRouter{}
+	Async  *AsyncRpc
+	Signer *SignVerifier
+	Ws     *WsHub
+
+	// GinAuth 网关鉴权, 须在注册路由前设置
+	GinAuth Authenticator
 )
 
 func RegRpc(service string, m ...string) {
@@ -33,6 +39,18 @@ func AddHandler(app string, h ...Handler) {
 	DISP.Add(app, h...)
 }
 
+func AddVersionHandler(app string, version int, h ...Handler) {
+	DISP.AddVersion(app, version, h...)
+}
+
+func DefaultVersion(app, method string, version int) {
+	DISP.DefaultVersion(app, method, version)
+}
+
+func Deprecate(app string, method ...string) {
+	DISP.Deprecate(app, method...)
+}
+
 func Override(app string, h ...Handler) {
 	DISP.Override(app, h...)
 }
@@ -45,6 +63,200 @@ func Forbid(app string, method ...string) {
 	DISP.Forbid(app, method...)
 }
 
+func UseInterceptor(i ...LpcInterceptor) {
+	UseLpcInterceptor(i...)
+}
+
+func UseAppInterceptor(app string, i ...LpcInterceptor) {
+	DISP.Use(app, i...)
+}
+
+func DisableApp(app ...string) {
+	DISP.DisableApp(app...)
+}
+
+func EnableApp(app ...string) {
+	DISP.EnableApp(app...)
+}
+
+func DisableHandler(app string, method ...string) {
+	DISP.Disable(app, method...)
+}
+
+func EnableHandler(app string, method ...string) {
+	DISP.Enable(app, method...)
+}
+
+// WatchHandlerSwitch 监听etcd中的app/method开关, 默认key为租户config/lpc
+func WatchHandlerSwitch(key ...string) {
+	k := ParseStrParam(key, etcdTenantConfigLpc)
+	DISP.WatchSwitch(GetEtcdContext(), k)
+}
+
+func PutHandlerSwitch(sw LpcSwitch, key ...string) error {
+	k := ParseStrParam(key, etcdTenantConfigLpc)
+
+	etcd := GetEtcdContext()
+	defer etcd.Close()
+
+	return PutLpcSwitch(Ctx, etcd, k, sw)
+}
+
+// SignMiddleware nonce存于redis db, 客户端配置默认取租户config/sign
+func SignMiddleware(db int, key ...string) gin.HandlerFunc {
+	Signer = NewSignVerifier(GetRedis(db))
+	Signer.WatchClients(GetEtcdContext(), ParseStrParam(key, etcdTenantSign))
+
+	return Signer.Middleware()
+}
+
+// InitRbac 权限表缓存于redis db, store为SqlRbacStore或EtcdRbacStore
+// secret用于签名rpc metadata中的user/role, 网关与各服务须一致
+func InitRbac(store RbacStore, db int, secret string) {
+	SetMdSecret(secret)
+
+	r := NewRbac(store, GetRedis(db))
+	err := r.Load(Ctx)
+	HandleInitErr("InitRbac", err)
+
+	etcd, ok := store.(EtcdRbacStore)
+	if ok {
+		etcd.Watch(r)
+	}
+
+	DISP.Rbac(r)
+}
+
+func CanCall(ctx context.Context, user, app, method string) bool {
+	if RBAC == nil {
+		return true
+	}
+
+	return RBAC.Can(ctx, user, app, method)
+}
+
+// WatchRoutes 监听etcd中的service -> app路由表, 默认key为租户config/route
+func WatchRoutes(key ...string) {
+	k := ParseStrParam(key, etcdTenantRoute)
+	Router.WatchRoutes(GetEtcdContext(), SERVER().ETCD(), k)
+}
+
+func PutRoutes(table RouteTable, key ...string) error {
+	k := ParseStrParam(key, etcdTenantRoute)
+
+	etcd := GetEtcdContext()
+	defer etcd.Close()
+
+	return PutRouteTable(Ctx, etcd, k, table)
+}
+
+// InitWs 网关启动websocket推送, redis db用于跨实例扇出与重连补发, origin为允许的跨域来源
+func InitWs(db int, origin ...string) {
+	WsOrigins = origin
+
+	Ws = NewWsHub(GetRedis(db))
+	RUN(Ws.Run)
+}
+
+// InitWsPush 其它服务只推送不接受连接, 须与网关使用同一redis db
+func InitWsPush(db int) {
+	Ws = NewWsHub(GetRedis(db))
+}
+
+// PushWs 须先InitWs或InitWsPush
+func PushWs(ctx context.Context, msg WsPush) (int64, error) {
+	if Ws == nil {
+		return 0, ErrOffline
+	}
+
+	return Ws.Push(ctx, msg)
+}
+
+// AuthMiddleware 未设置GinAuth时一律返回401
+func AuthMiddleware() gin.HandlerFunc {
+	return Authenticate(GinAuth)
+}
+
+// WsAuthMiddleware 浏览器websocket无法设置header, 仅/ws允许以query token传凭证
+func WsAuthMiddleware() gin.HandlerFunc {
+	auth := Authenticate(GinAuth)
+
+	var h = func(c *gin.Context) {
+		token := c.Query(authQuery)
+		if token != "" && c.GetHeader(HeadAToken) == "" {
+			c.Request.Header.Set(HeadAToken, token)
+		}
+
+		auth(c)
+	}
+
+	return h
+}
+
+func WsHandler() func(c *gin.Context) {
+	return Ws.Handler()
+}
+
+func WsStatHandler() func(c *gin.Context) {
+	return Ws.StatHandler()
+}
+
+// WatchGinCert 证书由etcd下发时调用, 未配置CertEtcd时不做处理
+func WatchGinCert(s *GinServer) {
+	if !s.Tls || s.CertEtcd == "" {
+		return
+	}
+
+	s.WatchCert(GetEtcdContext())
+}
+
+func IdempotentHandler(app string, method ...string) {
+	DISP.Idempotent(app, method...)
+}
+
+// PublishIdempotent 发布本服务的幂等标记供调用方决定重试, 默认key为租户config/idempotent/<app>
+func PublishIdempotent(key ...string) error {
+	k := ParseStrParam(key, etcdTenantIdempotent+EtcdDelimiter+SERVER().App)
+
+	etcd := GetEtcdContext()
+	defer etcd.Close()
+
+	return PutIdempotentMarks(Ctx, etcd, k)
+}
+
+// WatchIdempotent 调用方(网关)加载各服务发布的幂等标记
+func WatchIdempotent(key ...string) {
+	k := ParseStrParam(key, etcdTenantIdempotent)
+	WatchIdempotentMarks(GetEtcdContext(), k)
+}
+
+func SetRpcRetry(p RetryPolicy, app string, method ...string) {
+	SetRetryPolicy(p, app, method...)
+}
+
+// EnableHandlerCache 使用已初始化的redis db缓存只读方法
+func EnableHandlerCache(db int) {
+	SetCacheRedis(GetRedis(db))
+}
+
+func EnableIdempotency(db int) {
+	SetIdempotencyRedis(GetRedis(db))
+}
+
+// InitAsync 启动本实例的异步调用worker, 随GlobalContext退出
+func InitAsync(db int) {
+	Async = NewAsyncRpc(GetRedis(db))
+	RUN(Async.Run)
+}
+
+func AsyncCall(ctx context.Context, dst string, req *Req, wait time.Duration) (*Res, error) {
+	return Async.Call(ctx, dst, req, wait)
+}
+
+func CacheHandler(app string, ttl time.Duration, method ...string) {
+	DISP.Cache(app, ttl, method...)
+}
+
 func IgnoreHandlerLog(app string, f ...string) {
 	DISP.IgnoreLog(app, f...)
 }
@@ -136,12 +348,52 @@ func LpcHandler() func(c *gin.Context) {
 	return Router.LpcHandler()
 }
 
+func StreamHandler() func(c *gin.Context) {
+	return Router.StreamHandler()
+}
+
+func SseHandler() func(c *gin.Context) {
+	return Router.SseHandler()
+}
+
+func BatchHandler() func(c *gin.Context) {
+	return Router.BatchHandler()
+}
+
+func RbacMiddleware() gin.HandlerFunc {
+	return Router.RbacMiddleware()
+}
+
+func CanHandler() func(c *gin.Context) {
+	return Router.CanHandler()
+}
+
+func RouteHandler() func(c *gin.Context) {
+	return Router.RouteHandler()
+}
+
+func BreakerHandler() func(c *gin.Context) {
+	return Router.BreakerHandler()
+}
+
+func OpenApiHandler() func(c *gin.Context) {
+	return DISP.OpenApiHandler(ApiPrefix, ParseUrlDesc)
+}
+
+func JsonSchemaHandler() func(c *gin.Context) {
+	return DISP.JsonSchemaHandler(ApiPrefix, ParseUrlDesc)
+}
+
 func RPC(ctx context.Context,
 	app, method string, obj interface{},
 	res ...interface{}) (code int32, err error) {
 	return Router.Rpc(ctx, app, method, obj, res...)
 }
 
+func RpcMethod[In, Out any](app, method string) Method[In, Out] {
+	return NewMethod[In, Out](Router.Call, app, method)
+}
+
 func SafeGo(ctx context.Context, f JOB, msg ...string) {
 	Go(ctx, f, msg...)
 }
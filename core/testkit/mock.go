@@ -0,0 +1,385 @@
+package testkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	. "mykit/core/persist"
+	. "mykit/core/transfer"
+	. "mykit/core/types"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/micro/go-micro/v2/client"
+)
+
+// MockService 录制模式下代理真实client并把Req/Res写入fixture文件, 回放模式只读fixture
+// 同一请求录制多次时按顺序回放, 用尽后重复最后一次
+// 缺少fixture时返回CodeNoFixture而非传输错误, 不计入熔断也不触发重试
+
+type MockMode int8
+
+const (
+	MockReplay MockMode = iota
+	MockRecord
+)
+
+const (
+	CodeNoFixture = http.StatusNotImplemented
+
+	mockMissing = "no fixture for [%v]"
+)
+
+type mockBytes []byte
+
+// MarshalJSON json原样保存便于阅读, 其他编码以base64保存
+func (t mockBytes) MarshalJSON() ([]byte, error) {
+	if len(t) == 0 {
+		return ByteOfNullJson, nil
+	}
+
+	if json.Valid(t) {
+		var buf bytes.Buffer
+		err := json.Compact(&buf, t)
+		return buf.Bytes(), err
+	}
+
+	return json.Marshal(map[string]string{"$base64": base64.StdEncoding.EncodeToString(t)})
+}
+
+func (t *mockBytes) UnmarshalJSON(raw []byte) error {
+	var v struct {
+		Base64 *string `json:"$base64"`
+	}
+
+	if json.Unmarshal(raw, &v) == nil && v.Base64 != nil {
+		b, err := base64.StdEncoding.DecodeString(*v.Base64)
+		*t = b
+		return err
+	}
+
+	var buf bytes.Buffer
+	err := json.Compact(&buf, raw)
+	*t = buf.Bytes()
+
+	return err
+}
+
+type MockRes struct {
+	Code int32     `json:"code"`
+	Msg  string    `json:"msg"`
+	Data mockBytes `json:"data"`
+}
+
+type MockFixture struct {
+	App    string    `json:"app"`
+	Method string    `json:"method"`
+	Param  mockBytes `json:"param"`
+	Res    *MockRes  `json:"res,omitempty"`
+	Stream []MockRes `json:"stream,omitempty"`
+}
+
+func newMockRes(rsp *Res) MockRes {
+	res := MockRes{
+		Code: rsp.GetCode(),
+		Msg:  rsp.GetMsg(),
+		Data: rsp.GetData(),
+	}
+
+	return res
+}
+
+func (t MockRes) Res() *Res {
+	res := &Res{
+		Code: t.Code,
+		Msg:  t.Msg,
+		Data: t.Data,
+	}
+
+	return res
+}
+
+// canonicalParam json按key排序重新编码, 字段顺序与空白不影响匹配
+func canonicalParam(raw []byte) []byte {
+	if len(raw) == 0 || !json.Valid(raw) {
+		res, _ := mockBytes(raw).MarshalJSON()
+		return res
+	}
+
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+
+	var v interface{}
+	err := d.Decode(&v)
+	if err != nil {
+		return raw
+	}
+
+	res, err := json.Marshal(v)
+	if err != nil {
+		return raw
+	}
+
+	return res
+}
+
+func mockKey(req *Req) string {
+	return req.GetApp() + "." + req.GetMethod() + ":" + string(canonicalParam(req.GetParam()))
+}
+
+func NoFixtureRes(key string) *Res {
+	res := &Res{
+		Code: CodeNoFixture,
+		Msg:  fmt.Sprintf(mockMissing, key),
+		Data: ByteOfNullJson,
+	}
+
+	return res
+}
+
+type MockService struct {
+	sync.Mutex
+	mode   MockMode
+	file   string
+	target SmarterService
+
+	fixture []MockFixture
+	served  map[string]int
+	missed  []string
+}
+
+// NewRecorder 每次调用后立即写文件, 进程异常退出也不丢失已录制的请求
+func NewRecorder(target SmarterService, file string) *MockService {
+	res := &MockService{
+		mode:   MockRecord,
+		file:   file,
+		target: target,
+		served: map[string]int{},
+	}
+
+	return res
+}
+
+func NewReplayer(file string) (*MockService, error) {
+	res := &MockService{
+		mode:   MockReplay,
+		file:   file,
+		served: map[string]int{},
+	}
+
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(raw, &res.fixture)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (t *MockService) Mode() MockMode {
+	return t.mode
+}
+
+// Missed 回放模式下未找到fixture的请求
+func (t *MockService) Missed() []string {
+	t.Lock()
+	defer t.Unlock()
+
+	return append([]string{}, t.missed...)
+}
+
+func (t *MockService) save(f MockFixture) error {
+	t.Lock()
+	defer t.Unlock()
+
+	t.fixture = append(t.fixture, f)
+
+	raw, err := json.MarshalIndent(t.fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(t.file), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(t.file, raw, 0644)
+}
+
+func (t *MockService) find(req *Req, stream bool) (f MockFixture, ok bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	key := mockKey(req)
+
+	match := []MockFixture{}
+	for _, v := range t.fixture {
+		if mockKey(&Req{App: v.App, Method: v.Method, Param: v.Param}) != key {
+			continue
+		}
+
+		if stream == (v.Res == nil) {
+			match = append(match, v)
+		}
+	}
+
+	if len(match) == 0 {
+		t.missed = append(t.missed, key)
+		return f, false
+	}
+
+	n := t.served[key]
+	t.served[key] = n + 1
+
+	if n >= len(match) {
+		n = len(match) - 1
+	}
+
+	return match[n], true
+}
+
+func (t *MockService) Call(ctx context.Context, in *Req, opts ...client.CallOption) (*Res, error) {
+	if t.mode == MockReplay {
+		f, ok := t.find(in, false)
+		if !ok {
+			return NoFixtureRes(mockKey(in)), nil
+		}
+
+		return f.Res.Res(), nil
+	}
+
+	rsp, err := t.target.Call(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := newMockRes(rsp)
+	err = t.save(MockFixture{App: in.GetApp(), Method: in.GetMethod(), Param: in.GetParam(), Res: &res})
+
+	return rsp, err
+}
+
+func (t *MockService) Stream(ctx context.Context, in *Req, opts ...client.CallOption) (Smarter_StreamService, error) {
+	if t.mode == MockReplay {
+		f, ok := t.find(in, true)
+		if !ok {
+			return &mockStream{ctx: ctx, rsp: []MockRes{newMockRes(NoFixtureRes(mockKey(in)))}}, nil
+		}
+
+		return &mockStream{ctx: ctx, rsp: f.Stream}, nil
+	}
+
+	stream, err := t.target.Stream(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &mockStream{ctx: ctx, src: stream}
+	res.done = func(rsp []MockRes) error {
+		return t.save(MockFixture{App: in.GetApp(), Method: in.GetMethod(), Param: in.GetParam(), Stream: rsp})
+	}
+
+	return res, nil
+}
+
+// mockStream 回放时依次返回rsp, 录制时转发src并在EOF时保存
+type mockStream struct {
+	ctx  context.Context
+	rsp  []MockRes
+	src  Smarter_StreamService
+	done func([]MockRes) error
+}
+
+func (x *mockStream) Context() context.Context {
+	return x.ctx
+}
+
+func (x *mockStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (x *mockStream) RecvMsg(m interface{}) error {
+	rsp, err := x.Recv()
+	if err != nil {
+		return err
+	}
+
+	out, ok := m.(*Res)
+	if !ok {
+		return ErrInvalidParam
+	}
+
+	rsp.CloneTo(out)
+
+	return nil
+}
+
+func (x *mockStream) Close() error {
+	if x.src != nil {
+		return x.src.Close()
+	}
+
+	return nil
+}
+
+func (x *mockStream) Recv() (*Res, error) {
+	if x.src == nil {
+		if len(x.rsp) == 0 {
+			return nil, io.EOF
+		}
+
+		res := x.rsp[0].Res()
+		x.rsp = x.rsp[1:]
+
+		return res, nil
+	}
+
+	rsp, err := x.src.Recv()
+	if err == io.EOF && x.done != nil {
+		done := x.done
+		x.done = nil
+
+		saveErr := done(x.rsp)
+		if saveErr != nil {
+			return nil, saveErr
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	x.rsp = append(x.rsp, newMockRes(rsp))
+
+	return rsp, nil
+}
+
+// Record 以真实client录制, 每个service一个fixture文件
+func Record(router SmarterRouter, etcd EtcdConfig, dir string, service ...string) {
+	for _, v := range service {
+		router.AddService(v, NewRecorder(NewSmarterClient(v, etcd), filepath.Join(dir, v+".json")))
+	}
+}
+
+func Replay(router SmarterRouter, dir string, service ...string) error {
+	for _, v := range service {
+		mock, err := NewReplayer(filepath.Join(dir, v+".json"))
+		if err != nil {
+			return err
+		}
+
+		router.AddService(v, mock)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package testkit
+
+import (
+	"context"
+	. "mykit/core/dsp"
+	. "mykit/core/transfer"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type rbacStore RbacTable
+
+func (t rbacStore) Load(ctx context.Context) (RbacTable, error) {
+	return RbacTable(t), nil
+}
+
+func TestRbacSignedMd(t *testing.T) {
+	store := rbacStore{
+		Roles: map[string][]Permission{"echoer": {{App: "echo", Method: "*", Policy: PolicyWrite}}},
+		Users: map[string][]string{"alice": {"echoer"}},
+	}
+
+	kit := New(t).Add("echo", echoHandler{}).WithUser("alice")
+
+	SetMdSecret("secret")
+	kit.Disp.Rbac(NewRbac(store, nil))
+
+	_, res, _ := Call[echoOut](kit, "echo", "echo", echoIn{Name: "a"})
+	if res.GetCode() != http.StatusOK {
+		t.Fatalf("signed call should pass, got %d", res.GetCode())
+	}
+
+	//未签名的入站metadata即使冒充内部rpc用户或声明角色也应拒绝
+	forged := []map[string]string{
+		{TagUser: "@#smarter#@", TagTrace: kit.Trace},
+		{TagUser: "bob", TagRole: "echoer", TagTrace: kit.Trace},
+	}
+
+	for _, md := range forged {
+		res = &Res{}
+		kit.Disp.Call(NewIncomingContext(md), NewReq("echo", "echo", echoIn{Name: "a"}), res)
+		if res.GetCode() != RspCodeForbidden {
+			t.Fatalf("forged md %v should be forbidden, got %d", md, res.GetCode())
+		}
+	}
+
+	//签名覆盖role, 篡改后失效
+	md := map[string]string{TagUser: "bob", TagTrace: kit.Trace}
+	SignMd(md, "echo", "echo")
+	md[TagRole] = "echoer"
+
+	res = &Res{}
+	kit.Disp.Call(NewIncomingContext(md), NewReq("echo", "echo", echoIn{Name: "a"}), res)
+	if res.GetCode() != RspCodeForbidden {
+		t.Fatalf("tampered role should be forbidden, got %d", res.GetCode())
+	}
+
+	//签名绑定app/method, 不能用于其他方法
+	md = map[string]string{TagUser: "@#smarter#@", TagTrace: kit.Trace}
+	SignMd(md, "other", "any")
+
+	res = &Res{}
+	kit.Disp.Call(NewIncomingContext(md), NewReq("echo", "echo", echoIn{Name: "a"}), res)
+	if res.GetCode() != RspCodeForbidden {
+		t.Fatalf("replayed sign should be forbidden, got %d", res.GetCode())
+	}
+}
+
+func TestRbacStaleSign(t *testing.T) {
+	store := rbacStore{
+		Roles: map[string][]Permission{"echoer": {{App: "echo", Method: "*", Policy: PolicyWrite}}},
+		Users: map[string][]string{"alice": {"echoer"}},
+	}
+
+	kit := New(t).Add("echo", echoHandler{}).WithUser("alice")
+
+	SetMdSecret("secret")
+	kit.Disp.Rbac(NewRbac(store, nil))
+
+	skew := MdSignSkew
+	MdSignSkew = time.Nanosecond
+	defer func() {
+		MdSignSkew = skew
+	}()
+
+	ctx := kit.Ctx("echo", "echo")
+	time.Sleep(time.Millisecond)
+
+	res := &Res{}
+	kit.Disp.Call(ctx, NewReq("echo", "echo", echoIn{Name: "a"}), res)
+	if res.GetCode() != RspCodeForbidden {
+		t.Fatalf("stale sign should be forbidden, got %d", res.GetCode())
+	}
+}
@@ -0,0 +1,37 @@
+package testkit
+
+import (
+	. "mykit/core/transfer"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSseDone(t *testing.T) {
+	New(t)
+
+	DISP.Add("sse-echo", echoHandler{})
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/sse/:app/:method", SmarterRouter{}.SseHandler())
+
+	q := url.Values{"param": {`{"name":"a","n":2}`}}
+	req := httptest.NewRequest(http.MethodGet, "/sse/sse-echo/count?"+q.Encode(), nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	if strings.Count(body, "event: "+SseEventResult) != 2 {
+		t.Fatalf("want 2 result events, got %q", body)
+	}
+
+	//结束帧不作为result下发, 以done收尾且不带id
+	if !strings.HasSuffix(body, "event: "+SseEventDone+"\ndata: {}\n\n") || strings.Contains(body, "id: ") {
+		t.Fatalf("stream should end with done event, got %q", body)
+	}
+}
@@ -0,0 +1,160 @@
+// Package testkit 在进程内调用LPC handler, 无需SETUP、etcd与日志配置
+//
+//	kit := testkit.New(t).Add("report", ReportHandler{})
+//
+//	out, res, err := testkit.Call[Report](kit, "report", "getDayReport", param)
+package testkit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	. "mykit/core/dsp"
+	. "mykit/core/transfer"
+	"net/http"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var (
+	initOnce sync.Once
+)
+
+type Kit struct {
+	Disp *LpcDispatch
+	Logs *observer.ObservedLogs
+
+	User   string
+	Tenant string
+	Trace  string
+	Span   string
+	Meta   map[string]string
+}
+
+// New 使用独立的LpcDispatch, 日志写入Logs而非标准输出
+// 全局logger、拦截器、缓存、幂等与RBAC配置在用例内清空, 结束时由t.Cleanup恢复, 因此不可与t.Parallel同用
+func New(t testing.TB) *Kit {
+	initOnce.Do(func() {
+		InitLpc(false)
+	})
+
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	res := &Kit{
+		Disp:   NewLpcDispatch(),
+		Logs:   logs,
+		User:   "tester",
+		Tenant: "test",
+		Trace:  randHex(16),
+		Span:   randHex(8),
+		Meta:   map[string]string{},
+	}
+
+	t.Cleanup(UseLogCore(core))
+	t.Cleanup(IsolateLpc())
+
+	return res
+}
+
+func randHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func (t *Kit) Add(app string, h ...Handler) *Kit {
+	t.Disp.Add(app, h...)
+	return t
+}
+
+func (t *Kit) Use(app string, i ...LpcInterceptor) *Kit {
+	t.Disp.Use(app, i...)
+	return t
+}
+
+func (t *Kit) WithUser(user string) *Kit {
+	t.User = user
+	return t
+}
+
+func (t *Kit) WithTenant(tenant string) *Kit {
+	t.Tenant = tenant
+	return t
+}
+
+// WithMeta 追加任意metadata, 如language、codec、timeout
+func (t *Kit) WithMeta(k, v string) *Kit {
+	t.Meta[k] = v
+	return t
+}
+
+// Ctx 与grpc入站请求相同, 由HandleMd解析出user/tenant/trace, 签名只对app/method有效
+func (t *Kit) Ctx(app, method string) context.Context {
+	md := map[string]string{
+		TagUser:   t.User,
+		TagTenant: t.Tenant,
+		TagTrace:  t.Trace,
+		TagSpan:   t.Span,
+	}
+
+	for k, v := range t.Meta {
+		md[k] = v
+	}
+
+	//与网关转发的请求一样带签名, 启用RBAC时user/role可信
+	SignMd(md, app, method)
+
+	return NewIncomingContext(md)
+}
+
+// Invoke 经过拦截器、开关与日志, 与线上DISP.Call一致
+func (t *Kit) Invoke(app, method string, param interface{}) *Res {
+	res := &Res{}
+	t.Disp.Call(t.Ctx(app, method), NewReq(app, method, param), res)
+
+	return res
+}
+
+// Stream 收集流式方法的全部结果
+func (t *Kit) Stream(app, method string, param interface{}) (res []*Res, err error) {
+	err = t.Disp.StreamTo(t.Ctx(app, method), NewReq(app, method, param), func(rsp *Res) error {
+		res = append(res, rsp)
+		return nil
+	})
+
+	return
+}
+
+// Call 返回解码后的Data, code非200时err为*ErrorCode
+func Call[Out any](t *Kit, app, method string, param interface{}) (out Out, res *Res, err error) {
+	res = t.Invoke(app, method, param)
+
+	out, err = Decode[Out](res)
+
+	return
+}
+
+func Decode[Out any](res *Res) (out Out, err error) {
+	err = res.Err()
+	if res.GetCode() != http.StatusOK {
+		return
+	}
+
+	return DecodeData[Out](GetEncoder(CodecJson), res.GetData())
+}
+
+// Events 按event字段筛选日志
+func (t *Kit) Events(event string) []observer.LoggedEntry {
+	return t.Logs.FilterField(LogEvent(event)).All()
+}
+
+// Failed 返回error及以上级别的日志
+func (t *Kit) Failed() []observer.LoggedEntry {
+	return t.Logs.Filter(func(e observer.LoggedEntry) bool {
+		return e.Level >= zapcore.ErrorLevel
+	}).All()
+}
@@ -0,0 +1,176 @@
+package testkit
+
+import (
+	"context"
+	. "mykit/core/dsp"
+	. "mykit/core/transfer"
+	"net/http"
+	"testing"
+)
+
+type echoIn struct {
+	Name string `json:"name" validate:"required"`
+	N    int    `json:"n"`
+}
+
+type echoOut struct {
+	Name string `json:"name"`
+	User string `json:"user"`
+}
+
+type echoHandler struct{}
+
+func (t echoHandler) New(ctx context.Context) Handler {
+	return t
+}
+
+func (t echoHandler) Echo(ctx context.Context, in *echoIn) (*echoOut, error) {
+	return &echoOut{Name: in.Name, User: GetUser(ctx)}, nil
+}
+
+func (t echoHandler) Fail(ctx context.Context, in *echoIn) (*echoOut, error) {
+	return nil, NewErrorCode("bad name", 4001)
+}
+
+func (t echoHandler) Count(ctx context.Context, in *echoIn, send func(*echoOut) error) error {
+	for i := 0; i < in.N; i++ {
+		err := send(&echoOut{Name: in.Name})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Typed 返回*ErrorCode而非error, 不应注册为方法
+func (t echoHandler) Typed(ctx context.Context, in *echoIn) (*echoOut, *ErrorCode) {
+	return nil, nil
+}
+
+func TestCall(t *testing.T) {
+	kit := New(t).Add("echo", echoHandler{}).WithUser("alice")
+
+	out, res, err := Call[echoOut](kit, "echo", "echo", echoIn{Name: "a"})
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+
+	if res.GetCode() != http.StatusOK || out.Name != "a" || out.User != "alice" {
+		t.Fatalf("unexpected result: %d %+v", res.GetCode(), out)
+	}
+}
+
+func TestCallError(t *testing.T) {
+	kit := New(t).Add("echo", echoHandler{})
+
+	_, res, err := Call[echoOut](kit, "echo", "fail", echoIn{Name: "a"})
+	if err == nil || res.GetCode() != 4001 {
+		t.Fatalf("want code 4001, got %d %v", res.GetCode(), err)
+	}
+
+	_, res, _ = Call[echoOut](kit, "echo", "echo", echoIn{})
+	if res.GetCode() != CodeFailedOnRequired {
+		t.Fatalf("want validation failure, got %d", res.GetCode())
+	}
+
+	_, res, _ = Call[echoOut](kit, "echo", "typed", echoIn{Name: "a"})
+	if res.GetCode() != CodeUnimplemented {
+		t.Fatalf("method returning *ErrorCode must not be registered, got %d", res.GetCode())
+	}
+}
+
+func TestStream(t *testing.T) {
+	kit := New(t).Add("echo", echoHandler{})
+
+	res, err := kit.Stream("echo", "count", echoIn{Name: "a", N: 3})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+
+	if len(res) != 4 {
+		t.Fatalf("want 3 items and a status frame, got %d", len(res))
+	}
+
+	for _, v := range res[:3] {
+		if v.GetCode() != http.StatusOK {
+			t.Fatalf("unexpected item code %d", v.GetCode())
+		}
+	}
+
+	if !IsStreamEnd(res[3]) {
+		t.Fatalf("last frame should be stream end, got %d", res[3].GetCode())
+	}
+
+	res, _ = kit.Stream("echo", "nope", echoIn{Name: "a"})
+	if len(res) != 1 || res[0].GetCode() == http.StatusOK {
+		t.Fatalf("unknown method should end with one error frame, got %v", res)
+	}
+}
+
+func TestSwitch(t *testing.T) {
+	kit := New(t).Add("echo", echoHandler{})
+
+	other := New(t).Add("echo", echoHandler{})
+
+	//开关属于各自的LpcDispatch
+	kit.Disp.Disable("echo", "echo")
+
+	_, res, _ := Call[echoOut](kit, "echo", "echo", echoIn{Name: "a"})
+	if res.GetCode() == http.StatusOK {
+		t.Fatalf("disabled method should not be called")
+	}
+
+	_, res, _ = Call[echoOut](other, "echo", "echo", echoIn{Name: "a"})
+	if res.GetCode() != http.StatusOK {
+		t.Fatalf("switch leaked to another dispatch, got %d", res.GetCode())
+	}
+
+	kit.Disp.ApplySwitch(NewLpcSwitch())
+
+	_, res, _ = Call[echoOut](kit, "echo", "echo", echoIn{Name: "a"})
+	if res.GetCode() != http.StatusOK {
+		t.Fatalf("cleared switch should enable method, got %d", res.GetCode())
+	}
+}
+
+type echoV2Handler struct{}
+
+func (t echoV2Handler) New(ctx context.Context) Handler {
+	return t
+}
+
+func (t echoV2Handler) Echo(ctx context.Context, in *echoIn) (*echoOut, error) {
+	return &echoOut{Name: "v2:" + in.Name}, nil
+}
+
+func TestVersion(t *testing.T) {
+	kit := New(t).Add("echo", echoHandler{})
+
+	kit.Disp.AddVersion("echo", 3, echoV2Handler{})
+
+	cases := []struct {
+		method string
+		want   string
+	}{
+		{"echo", "v2:a"},
+		{"echo@v1", "a"},
+		{"echo@v2", "a"},
+		{"echo@v3", "v2:a"},
+		{"echo@v9", "v2:a"},
+	}
+
+	for _, v := range cases {
+		out, _, err := Call[echoOut](kit, "echo", v.method, echoIn{Name: "a"})
+		if err != nil || out.Name != v.want {
+			t.Fatalf("%s: want %q, got %q %v", v.method, v.want, out.Name, err)
+		}
+	}
+
+	kit.Disp.DefaultVersion("echo", "echo", 1)
+
+	out, _, _ := Call[echoOut](kit, "echo", "echo", echoIn{Name: "a"})
+	if out.Name != "a" {
+		t.Fatalf("want default v1, got %q", out.Name)
+	}
+}
@@ -0,0 +1,104 @@
+package testkit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	. "mykit/core/transfer"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func writeCert(t *testing.T, dir string) (cert, key string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert = filepath.Join(dir, "server.crt")
+	key = filepath.Join(dir, "server.key")
+	os.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: raw}), 0600)
+
+	return
+}
+
+func TestCertStoreClientAuth(t *testing.T) {
+	for _, v := range []string{"", ClientAuthRequire, ClientAuthRequest} {
+		_, err := NewCertStore(GinConfig{ClientAuth: v})
+		if err != nil {
+			t.Fatalf("%q should be accepted: %v", v, err)
+		}
+	}
+
+	_, err := NewCertStore(GinConfig{ClientAuth: "optional"})
+	if err != ErrClientAuth {
+		t.Fatalf("unknown client auth should be rejected, got %v", err)
+	}
+}
+
+// Tls为false时Run(true)也应加载证书
+func TestRunTlsWithoutTlsConfig(t *testing.T) {
+	New(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	cert, key := writeCert(t, t.TempDir())
+	srv := GinConfig{Uri: addr, Cert: cert, Key: key}.NewServer(true)
+	srv.Reg(func(e *gin.Engine) {
+		e.GET("/health", GinHeartbeat)
+	})
+
+	go srv.Run(true)
+	defer srv.Drain(context.Background())
+
+	cli := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	for i := 0; ; i++ {
+		rsp, err := cli.Get("https://" + addr + "/health")
+		if err == nil {
+			rsp.Body.Close()
+			return
+		}
+
+		if i > 50 {
+			t.Fatalf("https request failed: %v", err)
+		}
+
+		time.Sleep(time.Millisecond * 20)
+	}
+}
@@ -0,0 +1,100 @@
+package testkit
+
+import (
+	"errors"
+	"fmt"
+	. "mykit/core/transfer"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redismock/v8"
+	"github.com/gorilla/websocket"
+)
+
+func wsServer(hub *WsHub) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+
+	auth := func(c *gin.Context) (AuthInfo, error) {
+		if c.Query("token") != "good" {
+			return AuthInfo{}, errors.New("bad token")
+		}
+
+		return AuthInfo{User: "alice"}, nil
+	}
+
+	e := gin.New()
+	e.GET("/ws", Authenticate(auth), hub.Handler())
+
+	return httptest.NewServer(e)
+}
+
+func wsUrl(srv *httptest.Server, query string) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?" + query
+}
+
+func TestWsAuthAndOrigin(t *testing.T) {
+	New(t)
+
+	srv := wsServer(NewWsHub(nil))
+	defer srv.Close()
+
+	_, rsp, err := websocket.DefaultDialer.Dial(wsUrl(srv, "token=bad"), nil)
+	if err == nil || rsp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("want 401 without valid token, got %v", err)
+	}
+
+	h := http.Header{"Origin": {"https://evil.example"}}
+	_, rsp, err = websocket.DefaultDialer.Dial(wsUrl(srv, "token=good"), h)
+	if err == nil || rsp.StatusCode != http.StatusForbidden {
+		t.Fatalf("want 403 for foreign origin, got %v", err)
+	}
+
+	WsOrigins = []string{"https://evil.example"}
+	defer func() {
+		WsOrigins = nil
+	}()
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsUrl(srv, "token=good"), h)
+	if err != nil {
+		t.Fatalf("allowed origin: %v", err)
+	}
+	conn.Close()
+}
+
+func TestWsResumeBacklog(t *testing.T) {
+	New(t)
+
+	cli, mock := redismock.NewClientMock()
+
+	//信箱按LPUSH存放, 新消息在前
+	box := []string{}
+	for i := WsBacklog; i > 0; i-- {
+		box = append(box, fmt.Sprintf(`{"user":["alice"],"event":"message","data":{},"id":%d}`, i))
+	}
+	mock.ExpectLRange("lpc:ws:box::alice", 0, WsBacklog-1).SetVal(box)
+
+	srv := wsServer(NewWsHub(cli))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsUrl(srv, "token=good&last=1"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	//补发超过WsSendBuffer的消息也不应断开连接
+	for i := int64(2); i <= WsBacklog; i++ {
+		var f WsFrame
+		err = conn.ReadJSON(&f)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+
+		if f.Id != i {
+			t.Fatalf("want id %d, got %d", i, f.Id)
+		}
+	}
+}
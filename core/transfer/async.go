@@ -0,0 +1,256 @@
+package transfer
+
+import (
+	"context"
+	. "mykit/core/dsp"
+	. "mykit/core/types"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 异步调用: 请求写入Dst实例的stream, Dst的worker交给DISP执行后将响应写回Src实例的stream
+// 调用方按Trace与call匹配回调, 未收到响应的回调由reaper定期清理
+
+const (
+	asyncPrefix  = "lpc:async:"
+	asyncGroup   = "lpc"
+	asyncKind    = "kind"
+	asyncData    = "data"
+	asyncReq     = "req"
+	asyncRsp     = "rsp"
+	tagAsyncCall = "call"
+)
+
+var (
+	asyncSeq uint64
+)
+
+func AsyncStream(inst string) string {
+	return asyncPrefix + inst
+}
+
+type AsyncRpc struct {
+	cli  redis.Cmdable
+	inst string
+
+	Workers int
+	Block   time.Duration
+	MaxLen  int64
+	Reap    time.Duration
+}
+
+func NewAsyncRpc(cli redis.Cmdable, inst ...string) *AsyncRpc {
+	res := &AsyncRpc{
+		cli:     cli,
+		inst:    ParseStrParam(inst, INST()),
+		Workers: 8,
+		Block:   time.Second * 5,
+		MaxLen:  10000,
+		Reap:    time.Second * 10,
+	}
+
+	return res
+}
+
+func (t *AsyncRpc) Inst() string {
+	return t.inst
+}
+
+func (t *AsyncRpc) send(ctx context.Context, kind string, p InvocationParam) error {
+	a := &redis.XAddArgs{
+		Stream:       AsyncStream(p.Dst),
+		MaxLenApprox: t.MaxLen,
+		Values: map[string]interface{}{
+			asyncKind: kind,
+			asyncData: p.ToSend(),
+		},
+	}
+
+	return t.cli.XAdd(ctx, a).Err()
+}
+
+// Submit 只投递不等待响应
+func (t *AsyncRpc) Submit(ctx context.Context, dst string, req *Req, wait time.Duration) (p InvocationParam, err error) {
+	p = NewInvocationReq(ctx, t.inst, dst, req).InvocationParam()
+	p.Meta[tagAsyncCall] = t.inst + "-" + strconv.FormatUint(atomic.AddUint64(&asyncSeq, 1), 10)
+	p.Meta[TagTimeout] = strconv.FormatInt(wait.Milliseconds(), 10)
+	p.Sign()
+
+	//保留通道, 响应可能先于Call等待到达并移除登记
+	p.ch = cc.Register(p.Key(), wait)
+	p.wait = wait
+
+	err = t.send(ctx, asyncReq, p)
+	if err != nil {
+		cc.Remove(p.Key())
+	}
+
+	return
+}
+
+func (t *AsyncRpc) Call(ctx context.Context, dst string, req *Req, wait time.Duration) (*Res, error) {
+	p, err := t.Submit(ctx, dst, req, wait)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := p.GetResponse(ctx)
+
+	return rsp.Res(), err
+}
+
+// Run 阻塞至ctx结束
+func (t *AsyncRpc) Run(ctx context.Context) {
+	stream := AsyncStream(t.inst)
+
+	err := t.cli.XGroupCreateMkStream(ctx, stream, asyncGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		t.failed("group", err)
+		return
+	}
+
+	go cc.RunReaper(ctx, t.Reap)
+
+	jobs := make(chan redis.XMessage, t.Workers)
+	defer close(jobs)
+
+	for i := 0; i < t.Workers; i++ {
+		go func() {
+			for msg := range jobs {
+				t.handle(ctx, msg)
+				t.cli.XAck(ctx, stream, asyncGroup, msg.ID)
+			}
+		}()
+	}
+
+	t.drain(ctx, stream)
+
+	for ctx.Err() == nil {
+		res, err := t.read(ctx, stream, ">")
+		if err != nil {
+			if err != redis.Nil && ctx.Err() == nil {
+				t.failed("read", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		for _, s := range res {
+			for _, msg := range s.Messages {
+				jobs <- msg
+			}
+		}
+	}
+}
+
+func (t *AsyncRpc) read(ctx context.Context, stream, start string) ([]redis.XStream, error) {
+	a := &redis.XReadGroupArgs{
+		Group:    asyncGroup,
+		Consumer: t.inst,
+		Streams:  []string{stream, start},
+		Count:    int64(t.Workers),
+		Block:    t.Block,
+	}
+
+	return t.cli.XReadGroup(ctx, a).Result()
+}
+
+// drain 依次处理上次退出前已读取但未确认的消息
+func (t *AsyncRpc) drain(ctx context.Context, stream string) {
+	for ctx.Err() == nil {
+		res, err := t.read(ctx, stream, "0")
+		if err != nil {
+			return
+		}
+
+		n := 0
+		for _, s := range res {
+			for _, msg := range s.Messages {
+				n++
+				t.handle(ctx, msg)
+				t.cli.XAck(ctx, stream, asyncGroup, msg.ID)
+			}
+		}
+
+		if n == 0 {
+			return
+		}
+	}
+}
+
+func (t *AsyncRpc) handle(ctx context.Context, msg redis.XMessage) {
+	defer Recover("async rpc")
+
+	data, _ := msg.Values[asyncData].(string)
+
+	p := InvocationParam{}
+	err := UnmarshalJson(StringToBytes(data), &p)
+	if err != nil {
+		return
+	}
+
+	if msg.Values[asyncKind] == asyncRsp {
+		cc.Submit(p)
+		return
+	}
+
+	if p.Invalid(t.inst) {
+		return
+	}
+
+	if p.Meta == nil {
+		p.Meta = map[string]string{}
+	}
+
+	//扣除排队时间, 调用方已放弃的请求不再执行
+	timeout := ParseTimeout(p.Meta[TagTimeout])
+	if timeout > 0 {
+		timeout -= time.Since(streamTime(msg.ID))
+		if timeout <= 0 {
+			return
+		}
+
+		p.Meta[TagTimeout] = strconv.FormatInt(timeout.Milliseconds(), 10)
+	}
+
+	rsp := &Res{}
+	DISP.Call(p.Ctx(), p.Req(), rsp)
+
+	reply := InvocationParam{
+		Src:     t.inst,
+		Dst:     p.Src,
+		App:     p.App,
+		Method:  p.Method,
+		Trace:   p.Trace,
+		Meta:    p.Meta,
+		Payload: rsp.ToSend(),
+	}
+
+	err = t.send(ctx, asyncRsp, reply)
+	if err != nil {
+		t.failed("reply", err)
+	}
+}
+
+// streamTime stream消息id形如 毫秒时间戳-序号
+func streamTime(id string) time.Time {
+	ms, err := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+
+	return time.UnixMilli(ms)
+}
+
+func (t *AsyncRpc) failed(proc string, err error) {
+	ZapFailed(LogS1,
+		LogEvent("async rpc"),
+		LogProcessor(proc),
+		LogContent(t.inst),
+		LogError(err),
+	)
+}
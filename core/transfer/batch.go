@@ -0,0 +1,129 @@
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	. "mykit/core/dsp"
+	. "mykit/core/types"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	BatchConcurrency = 8
+	BatchMaxSize     = 50
+)
+
+type BatchItem struct {
+	App    string          `json:"app"`
+	Method string          `json:"method"`
+	Param  json.RawMessage `json:"param"`
+}
+
+func (t BatchItem) Req() *Req {
+	res := &Req{
+		App:    t.App,
+		Method: t.Method,
+		Param:  t.Param,
+	}
+
+	return res
+}
+
+// batchCall 结果与req一一对应, 单项失败不影响其余项
+func batchCall(ctx context.Context, req []*Req, limit int, call Caller) []*Res {
+	if limit < 1 {
+		limit = 1
+	}
+
+	res := make([]*Res, len(req))
+	sem := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for k, v := range req {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(k int, req *Req) {
+			defer func() {
+				if r := recover(); r != nil {
+					msg := fmt.Sprintf(callFailed, req.GetMethod())
+					res[k] = &Res{Code: CodeInternal, Msg: msg, Data: ByteOfNullJson}
+				}
+
+				<-sem
+				wg.Done()
+			}()
+
+			rsp, err := call(ctx, req)
+			if err != nil || rsp == nil {
+				msg := fmt.Sprintf(callFailed, req.GetApp())
+				rsp = &Res{Code: http.StatusInternalServerError, Msg: msg, Data: ByteOfNullJson}
+			}
+
+			res[k] = rsp
+		}(k, v)
+	}
+
+	wg.Wait()
+
+	return res
+}
+
+// Batch 并发执行, limit缺省为BatchConcurrency
+func (t SmarterRouter) Batch(ctx context.Context, req []*Req, limit ...int) []*Res {
+	return batchCall(ctx, req, ParseIntParam(limit, BatchConcurrency), t.dispatch)
+}
+
+func (t SmarterRouter) BatchCall(c *gin.Context) {
+	ctx, cancel := GinCallCtx(c)
+	defer cancel()
+
+	items := []BatchItem{}
+	err := json.Unmarshal(GetBodyFromGin(c), &items)
+	if err != nil || len(items) == 0 || len(items) > BatchMaxSize {
+		NewFinalRsp2(RspMsgBadRequest, CodeInvalidArgument).Send(c)
+		return
+	}
+
+	req := make([]*Req, len(items))
+	for k, v := range items {
+		req[k] = v.Req()
+	}
+
+	// gin.Context不能跨goroutine使用, 身份在分发前取出
+	codec := GinCodec(c)
+	user, role := GetUserAccount(c), GetRole(c)
+	rsp := batchCall(ctx, req, BatchConcurrency, func(ctx context.Context, req *Req) (*Res, error) {
+		deny := rbacCan(ctx, user, role, req.GetApp(), req.GetMethod())
+		if deny != nil {
+			return deny, nil
+		}
+
+		return transcodeCall(ctx, codec, req, t.dispatch)
+	})
+
+	res := make([]FinalRsp2, len(rsp))
+	for k, v := range rsp {
+		res[k] = FinalRsp2{
+			Code: v.GetCode(),
+			Msg:  v.GetMsg(),
+			Data: EnsureJsonByte(v.GetData()),
+		}
+	}
+
+	BeforeSend(c)
+
+	SuccessFinalRsp2(res).Send(c)
+}
+
+func (t SmarterRouter) BatchHandler() func(c *gin.Context) {
+	var h = func(c *gin.Context) {
+		t.BatchCall(c)
+	}
+
+	return h
+}
@@ -0,0 +1,392 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "mykit/core/dsp"
+	. "mykit/core/types"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BreakerState int32
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (t BreakerState) String() string {
+	switch t {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+type BreakerConfig struct {
+	Window      time.Duration //统计窗口
+	MinRequest  int64         //窗口内请求数达到后才判定
+	ErrorRate   float64       //失败率阈值, 0则不按失败率熔断
+	SlowCall    time.Duration //慢调用阈值
+	SlowRate    float64       //慢调用率阈值, 0则不按延迟熔断
+	OpenTimeout time.Duration //open持续时间, 之后进入half-open
+	HalfOpenMax int64         //half-open探测请求数
+	ProbeWait   time.Duration //探测请求未结束时释放名额的等待时间, 0则取OpenTimeout
+}
+
+var (
+	DefaultBreakerConfig = BreakerConfig{
+		Window:      time.Second * 10,
+		MinRequest:  20,
+		ErrorRate:   0.5,
+		SlowCall:    time.Second * 5,
+		SlowRate:    0.8,
+		OpenTimeout: time.Second * 30,
+		HalfOpenMax: 3,
+		ProbeWait:   time.Second * 10,
+	}
+
+	// BreakerFailure 业务错误码与调用方取消不计入失败
+	BreakerFailure = func(rsp *Res, err error) bool {
+		if errors.Is(err, context.Canceled) {
+			return false
+		}
+
+		if err != nil || rsp == nil {
+			return true
+		}
+
+		switch rsp.GetCode() {
+		case CodeInternal, CodeDeadlineExceeded, CodeUnavailable, http.StatusInternalServerError:
+			return true
+		}
+
+		return false
+	}
+)
+
+type BreakerStat struct {
+	Target string    `json:"target"`
+	State  string    `json:"state"`
+	Since  time.Time `json:"since"`
+	Total  int64     `json:"total"`
+	Failed int64     `json:"failed"`
+	Slow   int64     `json:"slow"`
+	Reject int64     `json:"reject"`
+}
+
+type Breaker struct {
+	sync.Mutex
+	target string
+	conf   BreakerConfig
+	state  BreakerState
+	since  time.Time
+	window time.Time
+	total  int64
+	failed int64
+	slow   int64
+	reject int64
+	probe  int64
+	passed int64
+	probed time.Time
+}
+
+func NewBreaker(target string, conf BreakerConfig) *Breaker {
+	now := time.Now()
+
+	res := &Breaker{
+		target: target,
+		conf:   conf,
+		since:  now,
+		window: now,
+	}
+
+	return res
+}
+
+// Allow open时快速失败, half-open时仅放行有限的探测请求
+func (t *Breaker) Allow() bool {
+	t.Lock()
+	defer t.Unlock()
+
+	now := time.Now()
+
+	switch t.state {
+	case BreakerOpen:
+		if now.Sub(t.since) < t.conf.OpenTimeout {
+			t.reject++
+			return false
+		}
+
+		t.shift(BreakerHalfOpen, now)
+		fallthrough
+
+	case BreakerHalfOpen:
+		if t.probe >= t.conf.HalfOpenMax {
+			//探测请求迟迟未Done时不再占用名额
+			if now.Sub(t.probed) < t.probeWait() {
+				t.reject++
+				return false
+			}
+
+			t.probe = t.passed
+		}
+
+		t.probe++
+		t.probed = now
+		return true
+	}
+
+	if now.Sub(t.window) > t.conf.Window {
+		t.reset(now)
+	}
+
+	return true
+}
+
+func (t *Breaker) Done(failed bool, cost time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+
+	now := time.Now()
+	slow := t.conf.SlowCall > 0 && cost > t.conf.SlowCall
+
+	switch t.state {
+	case BreakerHalfOpen:
+		if failed || slow {
+			t.shift(BreakerOpen, now)
+			return
+		}
+
+		t.passed++
+		if t.passed >= t.conf.HalfOpenMax {
+			t.shift(BreakerClosed, now)
+		}
+
+		return
+
+	case BreakerOpen:
+		return
+	}
+
+	t.total++
+	if failed {
+		t.failed++
+	}
+	if slow {
+		t.slow++
+	}
+
+	if t.total < t.conf.MinRequest {
+		return
+	}
+
+	total := float64(t.total)
+	if t.conf.ErrorRate > 0 && float64(t.failed)/total >= t.conf.ErrorRate {
+		t.shift(BreakerOpen, now)
+		return
+	}
+
+	if t.conf.SlowRate > 0 && float64(t.slow)/total >= t.conf.SlowRate {
+		t.shift(BreakerOpen, now)
+	}
+}
+
+// Release 请求未得出结果(如调用方取消)时归还探测名额, 不计入统计
+func (t *Breaker) Release() {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.state == BreakerHalfOpen && t.probe > t.passed {
+		t.probe--
+	}
+}
+
+func (t *Breaker) probeWait() time.Duration {
+	if t.conf.ProbeWait > 0 {
+		return t.conf.ProbeWait
+	}
+
+	return t.conf.OpenTimeout
+}
+
+// breakerDone 调用方已取消时结果不可信, 只归还名额
+func breakerDone(ctx context.Context, b *Breaker, failed bool, cost time.Duration) {
+	if b == nil {
+		return
+	}
+
+	if ctx.Err() == context.Canceled {
+		b.Release()
+		return
+	}
+
+	b.Done(failed, cost)
+}
+
+func (t *Breaker) State() BreakerState {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.state
+}
+
+func (t *Breaker) Stat() BreakerStat {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.stat()
+}
+
+func (t *Breaker) stat() BreakerStat {
+	res := BreakerStat{
+		Target: t.target,
+		State:  t.state.String(),
+		Since:  t.since,
+		Total:  t.total,
+		Failed: t.failed,
+		Slow:   t.slow,
+		Reject: t.reject,
+	}
+
+	return res
+}
+
+func (t *Breaker) reset(now time.Time) {
+	t.window = now
+	t.total = 0
+	t.failed = 0
+	t.slow = 0
+	t.probe = 0
+	t.passed = 0
+}
+
+func (t *Breaker) shift(state BreakerState, now time.Time) {
+	from := t.state
+
+	detail := t.stat()
+	detail.State = fmt.Sprintf(breakerShift, from, state)
+
+	t.state = state
+	t.since = now
+	t.reset(now)
+
+	LogS1.Warn(LogMsgGateway,
+		LogEvent("breaker"),
+		LogProcessor(t.target),
+		LogDetail(detail),
+	)
+}
+
+var (
+	breakerLock   sync.RWMutex
+	breakerConf   = map[string]BreakerConfig{}
+	breakers      = map[string]*Breaker{}
+	breakerEnable = true
+)
+
+func EnableBreaker(enable bool) {
+	breakerLock.Lock()
+	breakerEnable = enable
+	breakerLock.Unlock()
+}
+
+// SetBreaker 未指定target时修改默认配置, 已创建的熔断器会被重建
+func SetBreaker(conf BreakerConfig, target ...string) {
+	breakerLock.Lock()
+	defer breakerLock.Unlock()
+
+	if len(target) == 0 {
+		DefaultBreakerConfig = conf
+		breakers = map[string]*Breaker{}
+		return
+	}
+
+	for _, v := range target {
+		breakerConf[v] = conf
+		delete(breakers, v)
+	}
+}
+
+func GetBreaker(target string) *Breaker {
+	breakerLock.RLock()
+	b, ok := breakers[target]
+	enable := breakerEnable
+	breakerLock.RUnlock()
+
+	if !enable {
+		return nil
+	}
+
+	if ok {
+		return b
+	}
+
+	breakerLock.Lock()
+	defer breakerLock.Unlock()
+
+	b, ok = breakers[target]
+	if ok {
+		return b
+	}
+
+	conf, ok := breakerConf[target]
+	if !ok {
+		conf = DefaultBreakerConfig
+	}
+
+	b = NewBreaker(target, conf)
+	breakers[target] = b
+
+	return b
+}
+
+func BreakerStats() []BreakerStat {
+	breakerLock.RLock()
+	all := []*Breaker{}
+	for _, v := range breakers {
+		all = append(all, v)
+	}
+	breakerLock.RUnlock()
+
+	res := []BreakerStat{}
+	for _, v := range all {
+		res = append(res, v.Stat())
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Target < res[j].Target
+	})
+
+	return res
+}
+
+func BreakerOpenRes(app string) *Res {
+	res := &Res{
+		Code: RspCodeBreaker,
+		Msg:  fmt.Sprintf(breakerOpen, app),
+		Data: ByteOfNullJson,
+	}
+
+	return res
+}
+
+func (t SmarterRouter) BreakerHandler() func(c *gin.Context) {
+	var h = func(c *gin.Context) {
+		SuccessFinalRsp2(BreakerStats()).Send(c)
+	}
+
+	return h
+}
@@ -0,0 +1,186 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	. "mykit/core/dsp"
+	. "mykit/core/internal"
+	. "mykit/core/persist"
+	. "mykit/core/types"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 只读方法的响应缓存, 仅缓存code为200的结果, redis异常时直接调用方法
+
+const (
+	lpcCachePrefix = "lpc:cache:"
+	lpcCacheTag    = "lpc:cache:tag:"
+)
+
+// tagCommand 加入标签集合, 过期时间只延长不缩短(PTTL为-1即未设置), 避免短TTL的方法使集合先于长TTL的缓存过期
+const tagCommand = `redis.call("SADD", KEYS[1], ARGV[1])
+local exp = tonumber(ARGV[2])
+local ttl = redis.call("PTTL", KEYS[1])
+if exp > 0 and ttl < exp then
+    redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 1`
+
+type CacheRule struct {
+	TTL  time.Duration
+	Tags []string //失效标签, 缺省为app
+}
+
+var (
+	cacheLock  sync.RWMutex
+	cacheCli   redis.Cmdable
+	cacheRules = map[string]CacheRule{}
+)
+
+func SetCacheRedis(cli redis.Cmdable) {
+	cacheLock.Lock()
+	cacheCli = cli
+	cacheLock.Unlock()
+}
+
+func SetCacheRule(rule CacheRule, app string, method ...string) {
+	if len(rule.Tags) == 0 {
+		rule.Tags = []string{app}
+	}
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	for _, v := range method {
+		cacheRules[retryKey(app, v)] = rule
+	}
+}
+
+func GetCacheRule(app, method string) (rule CacheRule, cli redis.Cmdable, ok bool) {
+	cacheLock.RLock()
+	defer cacheLock.RUnlock()
+
+	if cacheCli == nil {
+		return
+	}
+
+	rule, ok = cacheRules[retryKey(app, method)]
+
+	return rule, cacheCli, ok && rule.TTL > 0
+}
+
+func cacheTenant(ctx context.Context) string {
+	return DeStrParam(GetTenant(ctx), Tenant())
+}
+
+func CacheKey(ctx context.Context, app string, req *Req) string {
+	h := sha1.New()
+	h.Write([]byte(EncoderName(GetCodec(ctx))))
+	h.Write(req.GetParam())
+
+	k := []string{cacheTenant(ctx), app, req.GetMethod(), hex.EncodeToString(h.Sum(nil))}
+
+	return lpcCachePrefix + strings.Join(k, ":")
+}
+
+func cacheTagKey(ctx context.Context, tag string) string {
+	return lpcCacheTag + cacheTenant(ctx) + ":" + tag
+}
+
+func loadCache(ctx context.Context, cli redis.Cmdable, key string) (*Res, bool) {
+	raw, err := cli.Get(ctx, key).Bytes()
+	if err != nil || len(raw) == 0 {
+		return nil, false
+	}
+
+	res := &Res{}
+	err = json.Unmarshal(raw, res)
+	if err != nil {
+		return nil, false
+	}
+
+	return res, true
+}
+
+func saveCache(ctx context.Context, cli redis.Cmdable, rule CacheRule, key string, rsp *Res) {
+	if rsp.GetCode() != http.StatusOK {
+		return
+	}
+
+	pipe := cli.TxPipeline()
+	pipe.Set(ctx, key, MustJsonMarshal(rsp), rule.TTL)
+	for _, v := range rule.Tags {
+		tag := cacheTagKey(ctx, v)
+		pipe.Eval(ctx, tagCommand, []string{tag}, key, rule.TTL.Milliseconds())
+	}
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		Logger(ctx).Failed(
+			LogEvent("lpc cache"),
+			LogProcessor(key),
+			LogError(err),
+		)
+	}
+}
+
+// cacheCall 命中缓存时不再执行方法, 拦截器仍会执行
+func (t *Lpc) cacheCall(ctx context.Context, req *Req, call LpcInvoker) (*Res, error) {
+	//影子流量不读写缓存, 否则比较的是缓存而非影子服务的结果
+	rule, cli, ok := GetCacheRule(t.app, req.GetMethod())
+	if !ok || IsShadow(ctx) {
+		return call(ctx, req)
+	}
+
+	key := CacheKey(ctx, t.app, req)
+
+	res, ok := loadCache(ctx, cli, key)
+	if ok {
+		return res, nil
+	}
+
+	res, err := call(ctx, req)
+	if err == nil && res != nil {
+		saveCache(ctx, cli, rule, key, res)
+	}
+
+	return res, err
+}
+
+// InvalidateCache 供写方法调用, 清除当前租户下打了这些标签的缓存
+func InvalidateCache(ctx context.Context, tag ...string) error {
+	cacheLock.RLock()
+	cli := cacheCli
+	cacheLock.RUnlock()
+
+	if cli == nil {
+		return nil
+	}
+
+	for _, v := range tag {
+		tagKey := cacheTagKey(ctx, v)
+
+		keys, err := cli.SMembers(ctx, tagKey).Result()
+		if RealRedisErr(err) {
+			return err
+		}
+
+		err = cli.Del(ctx, append(keys, tagKey)...).Err()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *LpcDispatch) Cache(app string, ttl time.Duration, method ...string) {
+	SetCacheRule(CacheRule{TTL: ttl}, app, method...)
+}
@@ -10,12 +10,14 @@ type Callback struct {
 	l    sync.Mutex
 	resp map[string]chan InvocationParam
 	wait map[string]time.Duration
+	dead map[string]time.Time
 }
 
 func NewCallback() *Callback {
 	res := &Callback{
 		resp: make(map[string]chan InvocationParam),
 		wait: make(map[string]time.Duration),
+		dead: make(map[string]time.Time),
 	}
 
 	return res
@@ -28,6 +30,7 @@ func (cc *Callback) Register(trace string, timeout time.Duration) chan Invocatio
 	res := make(chan InvocationParam, 1)
 	cc.resp[trace] = res
 	cc.wait[trace] = timeout
+	cc.dead[trace] = time.Now().Add(timeout)
 
 	return res
 }
@@ -36,44 +39,107 @@ func (cc *Callback) Submit(resp InvocationParam) {
 	cc.l.Lock()
 	defer cc.l.Unlock()
 
-	ch, ok := cc.resp[resp.Trace]
+	key := resp.Key()
+
+	ch, ok := cc.resp[key]
 	if !ok {
 		return
 	}
 
 	select {
 	case ch <- resp:
-		delete(cc.resp, resp.Trace)
-		delete(cc.wait, resp.Trace)
+		cc.remove(key)
 	default:
 
 	}
 }
 
-func (cc *Callback) GetResponse(ctx context.Context, trace, method string) (rsp *InvocationParam, err error) {
-	rsp = &InvocationParam{Trace: trace}
+func (cc *Callback) remove(trace string) {
+	delete(cc.resp, trace)
+	delete(cc.wait, trace)
+	delete(cc.dead, trace)
+}
+
+func (cc *Callback) Remove(trace string) {
+	cc.l.Lock()
+	cc.remove(trace)
+	cc.l.Unlock()
+}
 
+func (cc *Callback) Len() int {
+	cc.l.Lock()
+	defer cc.l.Unlock()
+
+	return len(cc.resp)
+}
+
+// Reap 清理超时仍未收到响应的回调, 返回清理数量
+func (cc *Callback) Reap() (n int) {
+	now := time.Now()
+
+	cc.l.Lock()
+	defer cc.l.Unlock()
+
+	for k, v := range cc.dead {
+		if now.After(v) {
+			cc.remove(k)
+			n++
+		}
+	}
+
+	return
+}
+
+func (cc *Callback) RunReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cc.Reap()
+		}
+	}
+}
+
+// GetResponse 响应可能先于调用到达并已移除登记, 持有Register返回的通道时应使用Wait
+func (cc *Callback) GetResponse(ctx context.Context, trace, method string) (rsp *InvocationParam, err error) {
 	cc.l.Lock()
 	respChan, ok := cc.resp[trace]
 	timeoutDuration, _ := cc.wait[trace]
 	cc.l.Unlock()
 
 	if !ok {
+		rsp = &InvocationParam{Trace: trace}
 		rsp.BadRequestRes(-1)
+		err = ErrCallbackNotFound
 		return
 	}
 
+	return cc.Wait(ctx, respChan, trace, method, timeoutDuration)
+}
+
+func (cc *Callback) Wait(ctx context.Context, respChan chan InvocationParam, trace, method string,
+	timeout time.Duration) (rsp *InvocationParam, err error) {
+	rsp = &InvocationParam{Trace: trace}
+
 	select {
 	case resp := <-respChan:
 		rsp = &resp
 		return
 
-	case <-time.After(timeoutDuration):
+	case <-time.After(timeout):
+		cc.Remove(trace)
 		rsp.TimeoutRes(method)
+		err = ErrCallbackTimeout
 		return
 
 	case <-ctx.Done():
+		cc.Remove(trace)
 		rsp.TimeoutRes(method)
+		err = ErrCallbackTimeout
 		return
 	}
 }
@@ -0,0 +1,163 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	. "mykit/core/dsp"
+	. "mykit/core/types"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/proto"
+)
+
+// Req.Param与成功Res.Data按metadata中的codec编码, 缺省为json
+
+func WithCodec(ctx context.Context, codec string) context.Context {
+	if codec == "" {
+		return ctx
+	}
+
+	return MergeMetaContext(ctx, map[string]string{TagCodec: codec})
+}
+
+func GetCodec(ctx context.Context) Encoder {
+	return GetEncoder(GetStringFromContext(ctx, TagCodec))
+}
+
+// GinCodec 网关只接受可与json互转的codec
+func GinCodec(c *gin.Context) string {
+	codec := c.GetHeader(HeadCodec)
+	if codec == "" || codec == CodecJson || !HasEncoder(codec) {
+		return ""
+	}
+
+	_, ok := GetEncoder(codec).(Transcoder)
+	if !ok {
+		return ""
+	}
+
+	return codec
+}
+
+func NewReqWithCodec(app, method string, obj interface{}, codec string) (*Req, error) {
+	enc := GetEncoder(codec)
+	if IsJsonEncoder(enc) {
+		return NewReq(app, method, obj), nil
+	}
+
+	param, err := enc.Encode(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Req{
+		App:    app,
+		Method: method,
+		Param:  param,
+	}
+
+	return res, nil
+}
+
+func decodeParam(enc Encoder, req *Req, param interface{}) error {
+	if IsJsonEncoder(enc) {
+		return UnmarshalJson(req.Payload(), param)
+	}
+
+	if len(req.GetParam()) == 0 {
+		return nil
+	}
+
+	return enc.Decode(req.GetParam(), param)
+}
+
+func encodeData(enc Encoder, value reflect.Value) ([]byte, error) {
+	if IsJsonEncoder(enc) {
+		return RespJsonMarshalValue(value), nil
+	}
+
+	if value.IsNil() {
+		return []byte{}, nil
+	}
+
+	return enc.Encode(value.Interface())
+}
+
+// DecodeData 对proto指针类型的Out先分配再解码
+func DecodeData[Out any](enc Encoder, raw []byte) (out Out, err error) {
+	if IsJsonEncoder(enc) {
+		err = UnmarshalJson(raw, &out)
+		return
+	}
+
+	if len(raw) == 0 {
+		return
+	}
+
+	_, ok := any(out).(proto.Message)
+	if ok {
+		v := reflect.New(reflect.TypeOf(out).Elem()).Interface()
+		err = enc.Decode(raw, v)
+		out = v.(Out)
+		return
+	}
+
+	err = enc.Decode(raw, &out)
+
+	return
+}
+
+func transcodeParam(codec string, req *Req) error {
+	if codec == "" {
+		return nil
+	}
+
+	tc := GetEncoder(codec).(Transcoder)
+
+	param, err := tc.FromJson(req.GetParam())
+	if err != nil {
+		return err
+	}
+
+	req.Param = param
+
+	return nil
+}
+
+func transcodeRes(codec string, req *Req, rsp *Res) *Res {
+	if codec == "" || rsp == nil || rsp.GetCode() != http.StatusOK {
+		return rsp
+	}
+
+	tc := GetEncoder(codec).(Transcoder)
+
+	data, err := tc.ToJson(rsp.GetData())
+	if err != nil {
+		msg := fmt.Sprintf(callFailed, req.GetMethod())
+		return &Res{Code: CodeInternal, Msg: msg, Data: ByteOfNullJson}
+	}
+
+	rsp.Data = data
+
+	return rsp
+}
+
+func transcodeCall(ctx context.Context, codec string, req *Req, call Caller) (rsp *Res, err error) {
+	if codec == "" {
+		return call(ctx, req)
+	}
+
+	err = transcodeParam(codec, req)
+	if err != nil {
+		return BadRequestRes(CodeInvalidArgument), nil
+	}
+
+	rsp, err = call(WithCodec(ctx, codec), req)
+	if err != nil {
+		return
+	}
+
+	return transcodeRes(codec, req, rsp), nil
+}
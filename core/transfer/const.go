@@ -9,20 +9,35 @@ const (
 	RpcUtils = "utils"
 )
 
+const (
+	ApiPrefix = "/api"
+)
+
 const (
 	logImport      = "import"
 	logOverride    = "override"
+	logPolicy      = "policy"
 	importPat      = "app [%v] import [%v]"
 	overridePat    = "app [%v] override [%v]"
+	policyPat      = "app [%v] policy [%v] %v"
 	unknownMethod  = "unknown method [%v]"
 	invalidMethod  = "invalid method [%v], code %v"
 	invalidLpcMeta = "reg invalid lpc meta to [%v]"
 	dupedLpcMeta   = "[%v] has imported to [%v]"
 	appNotImpl     = "app [%v] not implemented"
 	methodNotImpl  = "method [%v] not implemented"
+	methodDisabled = "app [%v] method [%v] disabled"
 	decodeErr      = "decode err, payload size %v"
 	callFailed     = "call [%v] failed"
 	callTimeout    = "call [%v] timeout"
+	streamOnly     = "method [%v] only supports stream"
+	breakerOpen    = "app [%v] circuit open"
+	breakerShift   = "%v -> %v"
+	idemConflict   = "request [%v] in progress"
+	idemMismatch   = "idempotency key [%v] reused with different param"
+	roleRequired   = "method [%v] requires role %v"
+	rbacDenied     = "app [%v] method [%v] forbidden"
+	notReady       = "not ready"
 )
 
 const (
@@ -40,6 +55,11 @@ const (
 	ApplicationForm = "application/x-www-form-urlencoded"
 )
 
+const (
+	JsonLineType        = "application/x-ndjson; charset=utf-8"
+	ApplicationJsonLine = "application/x-ndjson"
+)
+
 const (
 	ProtocolWeb  = "web"
 	ProtocolApp  = "app"
@@ -63,6 +83,9 @@ const (
 	HeadCredential = "Credential"
 	HeadNonce      = "Nonce"
 	HeadLanguage   = "Language"
+	HeadCodec      = "Codec"
+	HeadTimeout    = "Timeout"
+	HeadVersion    = "Version"
 	HeadTenant     = "Tenant"
 	HeadTs         = "Ts"
 	HeadRole       = "Role"
@@ -0,0 +1,101 @@
+package transfer
+
+import (
+	"context"
+	. "mykit/core/dsp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 剩余超时以毫秒写入metadata的lpc-timeout, 每一跳按收到时刻重建deadline, 避免依赖各节点时钟一致
+// timeout为go-micro保留的key(纳秒, 客户端覆盖、服务端删除), 不可复用
+
+var (
+	// MaxGinTimeout 客户端Timeout头的上限, 0则不限制
+	MaxGinTimeout time.Duration = 0
+)
+
+// ParseTimeout 支持毫秒数或time.Duration格式, 如 1500、1.5s
+func ParseTimeout(raw string) time.Duration {
+	if raw == "" {
+		return 0
+	}
+
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	if err == nil {
+		return time.Duration(ms) * time.Millisecond
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0
+	}
+
+	return d
+}
+
+func remainTimeout(ctx context.Context) string {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ""
+	}
+
+	ms := time.Until(deadline).Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+
+	return strconv.FormatInt(ms, 10)
+}
+
+// WithTimeoutMeta 将ctx剩余时间写入出站metadata
+func WithTimeoutMeta(ctx context.Context) context.Context {
+	timeout := remainTimeout(ctx)
+	if timeout == "" {
+		return ctx
+	}
+
+	return MergeMetaContext(ctx, map[string]string{TagTimeout: timeout})
+}
+
+func GetTimeout(ctx context.Context) time.Duration {
+	return ParseTimeout(GetStringFromContext(ctx, TagTimeout))
+}
+
+// WithMdDeadline 按HandleMd解析出的timeout设置deadline, 已有更早的deadline时不变
+func WithMdDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := GetTimeout(ctx)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// GinCallCtx 客户端断开或Timeout头到期时取消
+func GinCallCtx(c *gin.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(CallCtx(c))
+	stop := context.AfterFunc(c.Request.Context(), cancel)
+
+	timeout := ParseTimeout(c.GetHeader(HeadTimeout))
+	if MaxGinTimeout > 0 && (timeout <= 0 || timeout > MaxGinTimeout) {
+		timeout = MaxGinTimeout
+	}
+
+	if timeout <= 0 {
+		return ctx, func() {
+			stop()
+			cancel()
+		}
+	}
+
+	ctx, cancel2 := context.WithTimeout(ctx, timeout)
+
+	return ctx, func() {
+		cancel2()
+		stop()
+		cancel()
+	}
+}
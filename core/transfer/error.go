@@ -11,4 +11,11 @@ var (
 	ErrInvalidSegmentParam = errors.New("invalid segment param")
 	ErrInvalidNsqParam     = errors.New("invalid nsq param")
 	ErrInvalidNatsParam    = errors.New("invalid nats param")
+	ErrSignClient          = errors.New("unknown sign client")
+	ErrSignStale           = errors.New("stale sign timestamp")
+	ErrSignReplay          = errors.New("sign nonce replayed")
+	ErrSignInvalid         = errors.New("invalid sign")
+	ErrCallbackNotFound    = errors.New("callback not registered")
+	ErrCallbackTimeout     = errors.New("callback timeout")
+	ErrTokenExpired        = errors.New("token expired")
 )
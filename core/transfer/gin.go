@@ -1,15 +1,19 @@
 package transfer
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	. "mykit/core/dsp"
+	. "mykit/core/internal"
+	. "mykit/core/persist"
 	. "mykit/core/types"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-contrib/gzip"
@@ -27,6 +31,13 @@ type GinConfig struct {
 	StaticPath string `json:",default=/assets"`    //Static Path
 	StaticDir  string `json:",default=./assets"`   //Static Dir
 	address    string `json:",optional"`
+
+	DrainDelayMs   uint64 `json:",default=5000"`  //健康检查置为未就绪后等待负载均衡摘除的时间
+	DrainTimeoutMs uint64 `json:",default=15000"` //等待进行中请求完成的上限, 超时后强制关闭
+
+	CertEtcd   string `json:",optional"`        //证书所在etcd key, 值为CertPair, 设置后不读Cert/Key文件
+	ClientCa   string `json:",optional"`        //客户端CA文件, 设置后启用mTLS
+	ClientAuth string `json:",default=require"` //require或request
 }
 
 func (t *GinConfig) Address() string {
@@ -60,6 +71,9 @@ func (t GinConfig) NewServer(release bool, middleware ...gin.HandlerFunc) *GinSe
 type GinServer struct {
 	GinConfig
 	*gin.Engine
+	srv  *http.Server
+	cert *CertStore
+	once sync.Once
 }
 
 func NewGinServer(conf GinConfig, release bool, middleware ...gin.HandlerFunc) *GinServer {
@@ -79,11 +93,56 @@ func NewGinServer(conf GinConfig, release bool, middleware ...gin.HandlerFunc) *
 		middleware = append(middleware, gzipMiddleware)
 	}
 
+	//Run(true)时即使Tls为false也会启用TLS, 非TLS请求无客户端证书, 中间件为空操作
+	if conf.ClientCa != "" {
+		middleware = append(middleware, ClientCertMiddleware())
+	}
+
 	InitGin(res.Engine, release, middleware...)
 
+	res.srv = res.NewServer()
+
+	if conf.Tls {
+		res.initTls()
+	}
+
 	return res
 }
 
+// initTls 可重复调用, 仅首次生效
+func (t *GinServer) initTls() {
+	t.once.Do(t.loadTls)
+}
+
+func (t *GinServer) loadTls() {
+	cert, err := NewCertStore(t.GinConfig)
+	HandleInitErr("tls client auth", err, true)
+
+	t.cert = cert
+
+	err = t.cert.Load()
+	HandleInitErr("load cert", err)
+
+	t.srv.TLSConfig = t.cert.TLSConfig()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.srv.RegisterOnShutdown(cancel)
+
+	go t.cert.Watch(ctx)
+}
+
+// WatchCert 证书改由etcd下发, CertEtcd为空时不做处理
+func (t *GinServer) WatchCert(etcd *EtcdContext) {
+	if t.CertEtcd == "" {
+		return
+	}
+
+	t.initTls()
+
+	err := t.cert.WatchEtcd(etcd, t.CertEtcd)
+	HandleInitErr("watch cert "+t.CertEtcd, err)
+}
+
 func (t *GinServer) NewServer() *http.Server {
 	srv := &http.Server{
 		Addr:        t.Uri,
@@ -128,17 +187,55 @@ func (t *GinServer) Run(tls ...bool) {
 func (t *GinServer) RunHttp() {
 	defer Recover("run http")
 
-	err := t.NewServer().ListenAndServe()
+	AddDrainJob(t.Drain)
+
+	err := t.srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return
+	}
+
 	HandleInitErr("run gin http", err)
 }
 
 func (t *GinServer) RunTLS() {
 	defer Recover("run tls error:")
 
-	err := t.NewServer().ListenAndServeTLS(t.Cert, t.Key)
+	//Tls为false时经Run(true)或直接调用进入, 此时才初始化证书
+	t.initTls()
+
+	AddDrainJob(t.Drain)
+
+	//证书由CertStore在握手时提供
+	err := t.srv.ListenAndServeTLS("", "")
+	if err == http.ErrServerClosed {
+		return
+	}
+
 	HandleInitErr("run gin tls", err)
 }
 
+// Drain 由TEARDOWN调用: 此时健康检查已返回未就绪, 等待DrainDelayMs后停止接收新连接,
+// 进行中的请求在DrainTimeoutMs内完成, 超时则强制关闭
+func (t *GinServer) Drain(ctx context.Context) {
+	time.Sleep(MsTimeout(t.DrainDelayMs))
+
+	ctx, cancel := context.WithTimeout(ctx, MsTimeout(t.DrainTimeoutMs))
+	defer cancel()
+
+	t0 := time.Now()
+	err := t.srv.Shutdown(ctx)
+	if err != nil {
+		t.srv.Close()
+	}
+
+	LogS1.Info(LogMsgSetup,
+		LogEvent("drain"),
+		LogProcessor(t.Uri),
+		LogDuration(time.Now().Sub(t0)),
+		LogError(err),
+	)
+}
+
 type GinProxy struct {
 	c *gin.Context
 	p *httputil.ReverseProxy
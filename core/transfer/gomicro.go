@@ -3,6 +3,8 @@ package transfer
 import (
 	. "mykit/core/persist"
 	. "mykit/core/types"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/micro/go-micro/v2"
@@ -16,6 +18,11 @@ var (
 	rpcPrefix  = "srv.lixx."
 )
 
+var (
+	clientLock sync.Mutex
+	clients    = map[string]client.Client{}
+)
+
 func SetRpcPrefix(raw string) {
 	rpcPrefix = raw
 }
@@ -68,7 +75,19 @@ func RegistryClient(app string, etcd EtcdConfig) micro.Service {
 	return service
 }
 
-func NewSmarterClient(app string, etcd EtcdConfig) SmarterService {
+// sharedClient 同一etcd的service共用client, 连接与注册中心监听不随service增删
+// 初始化失败的client不缓存, 下次重新创建
+func sharedClient(app string, etcd EtcdConfig) (client.Client, error) {
+	key := strings.Join(etcd.Hosts, ",") + "@" + etcd.User
+
+	clientLock.Lock()
+	defer clientLock.Unlock()
+
+	c, ok := clients[key]
+	if ok {
+		return c, nil
+	}
+
 	service := RegistryClient(app, etcd)
 
 	err := service.Client().Init(
@@ -77,7 +96,18 @@ func NewSmarterClient(app string, etcd EtcdConfig) SmarterService {
 		cgrpc.MaxSendMsgSize(MaxMsgSize),
 		cgrpc.MaxRecvMsgSize(MaxMsgSize),
 	)
+	if err != nil {
+		return service.Client(), err
+	}
+
+	clients[key] = service.Client()
+
+	return service.Client(), nil
+}
+
+func NewSmarterClient(app string, etcd EtcdConfig) SmarterService {
+	c, err := sharedClient(app, etcd)
 	HandleInitErr("Micro Client init", err)
 
-	return NewSmarterService(app, service.Client())
+	return NewSmarterService(app, c)
 }
@@ -18,6 +18,10 @@ var (
 
 func SetRpcRetryTimes(t int32) {
 	atomic.StoreInt32(&rpcRetryTimes, t)
+
+	retryLock.Lock()
+	DefaultRetryPolicy.MaxAttempts = int(t)
+	retryLock.Unlock()
 }
 
 func GetRpcRetryTimes() int32 {
@@ -34,7 +38,7 @@ func SmarterGrpcClient(address string) SmarterClient {
 }
 
 func SmarterCall(client SmarterClient, ctx context.Context, req *Req) (rsp *Res, err error) {
-	c := RpcCtx(ctx)
+	c := signCtx(RpcCtx(ctx), req)
 
 	rsp, err = client.Call(c, req)
 	if err != nil {
@@ -46,11 +50,14 @@ func SmarterCall(client SmarterClient, ctx context.Context, req *Req) (rsp *Res,
 	return
 }
 
+// RpcCall 按app/method的重试策略调用, 非幂等方法不重试
 func RpcCall(client SmarterClient,
 	latency time.Duration,
 	req *Req, res ...interface{}) (err error) {
 
-	return RpcCallWithTimeoutAndInterval(client, int(GetRpcRetryTimes()), latency, Interval100ms, req, res...)
+	policy := RetryFor(req.GetApp(), req.GetMethod())
+
+	return RpcCallWithPolicy(client, latency, policy, req, res...)
 }
 
 func RpcCallWithTimeout(client SmarterClient,
@@ -66,28 +73,39 @@ func RpcCallWithTimeoutAndInterval(client SmarterClient,
 	latency time.Duration, interval TaskInterval,
 	req *Req, res ...interface{}) (err error) {
 
+	//显式指定retry即由调用方确认可重试; 与原行为一致, 只重试传输错误, 按code重试须经RpcCallWithPolicy
+	policy := GetRetryPolicy(req.GetApp(), req.GetMethod())
+	policy.MaxAttempts = retry
+	policy.Interval = interval
+	policy.Codes = nil
+
+	return RpcCallWithPolicy(client, latency, policy, req, res...)
+}
+
+func RpcCallWithPolicy(client SmarterClient,
+	latency time.Duration, policy RetryPolicy,
+	req *Req, res ...interface{}) (err error) {
+
 	ctx, cancel := context.WithTimeout(Ctx, latency)
 	defer cancel()
 
-	var rsp *Res
-	for i := 0; i < retry; i++ {
-		select {
-		case <-ctx.Done():
+	rsp, err := policy.Do(ctx, func(ctx context.Context) (*Res, error) {
+		return SmarterCall(client, ctx, req)
+	})
+
+	if err != nil {
+		if ctx.Err() != nil {
 			return ErrorCodeTimeout
-		default:
 		}
 
-		if rsp, err = SmarterCall(client, ctx, req); err == nil {
-			if len(res) > 0 {
-				err = UnmarshalJson(rsp.GetData(), &res[0])
-			}
-			return
-		}
+		return
+	}
 
-		time.Sleep(interval())
+	if len(res) > 0 {
+		err = UnmarshalJson(rsp.GetData(), &res[0])
 	}
 
-	return err
+	return
 }
 
 type GrpcLogSender struct {
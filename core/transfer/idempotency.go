@@ -0,0 +1,218 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	. "mykit/core/dsp"
+	. "mykit/core/persist"
+	. "mykit/core/types"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 客户端以Idempotency-Key头标识一次写操作, 首次结果存入redis
+// 执行中的重复请求等待至多IdempotencyWait后返回冲突, TTL内的重放直接返回首次结果
+
+const (
+	HeadIdempotencyKey = "Idempotency-Key"
+	HeadIdempotency    = "Idempotency"
+	TagIdempotency     = "idempotency"
+
+	lpcIdemPrefix = "lpc:idem:"
+	idemPending   = "pending"
+	idemDone      = "done"
+)
+
+// renewCommand 仅在仍为本次占位时续期
+const renewCommand = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`
+
+var (
+	IdempotencyTTL  = time.Hour * 24
+	IdempotencyLock = time.Second * 30 //执行中的占位有效期, 执行期间自动续期, 节点崩溃后过期
+	IdempotencyWait = time.Second * 5
+	IdempotencyPoll = time.Millisecond * 50
+)
+
+var (
+	idemLock sync.RWMutex
+	idemCli  redis.Cmdable
+)
+
+type idemRecord struct {
+	State string `json:"state"`
+	Hash  string `json:"hash"`
+	Res   *Res   `json:"res,omitempty"`
+}
+
+func SetIdempotencyRedis(cli redis.Cmdable) {
+	idemLock.Lock()
+	idemCli = cli
+	idemLock.Unlock()
+}
+
+func getIdemCli() redis.Cmdable {
+	idemLock.RLock()
+	defer idemLock.RUnlock()
+
+	return idemCli
+}
+
+func GetIdempotencyKey(ctx context.Context) string {
+	return GetStringFromContext(ctx, TagIdempotency)
+}
+
+func idemKey(ctx context.Context, app, method, key string) string {
+	k := []string{cacheTenant(ctx), GetUser(ctx), app, method, key}
+	return lpcIdemPrefix + strings.Join(k, ":")
+}
+
+func paramHash(req *Req) string {
+	h := sha1.Sum(req.GetParam())
+	return hex.EncodeToString(h[:])
+}
+
+func ConflictRes(key string) *Res {
+	res := &Res{
+		Code: RspCodeConflict,
+		Msg:  fmt.Sprintf(idemConflict, key),
+		Data: ByteOfNullJson,
+	}
+
+	return res
+}
+
+func IdempotencyMismatchRes(key string) *Res {
+	res := &Res{
+		Code: CodeInvalidArgument,
+		Msg:  fmt.Sprintf(idemMismatch, key),
+		Data: ByteOfNullJson,
+	}
+
+	return res
+}
+
+func loadIdem(ctx context.Context, cli redis.Cmdable, key string) (record idemRecord, err error) {
+	raw, err := cli.Get(ctx, key).Bytes()
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(raw, &record)
+
+	return
+}
+
+// keepPending 执行期间定期续期占位, 节点崩溃后占位在IdempotencyLock内过期
+func keepPending(ctx context.Context, cli redis.Cmdable, key string, pending []byte) (stop func()) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	go func() {
+		ticker := time.NewTicker(IdempotencyLock / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				cli.Eval(ctx, renewCommand, []string{key}, pending, IdempotencyLock.Milliseconds())
+			}
+		}
+	}()
+
+	return cancel
+}
+
+func firstCall(ctx context.Context, cli redis.Cmdable, k, hash string, pending []byte, req *Req,
+	call func(ctx context.Context, req *Req) *Res) *Res {
+	stop := keepPending(ctx, cli, k, pending)
+	res := call(ctx, req)
+	stop()
+
+	//请求超时后仍须写回结果
+	bg := context.WithoutCancel(ctx)
+
+	//可重试的失败不保存, 允许客户端以同一key重试
+	if GetRetryPolicy(req.GetApp(), req.GetMethod()).Retryable(res, nil) {
+		cli.Del(bg, k)
+		return res
+	}
+
+	done := MustJsonMarshal(idemRecord{State: idemDone, Hash: hash, Res: res})
+	err := cli.Set(bg, k, done, IdempotencyTTL).Err()
+	if err != nil {
+		//结果未保存时释放占位, 重放将再次执行而非一直冲突
+		cli.Del(bg, k)
+
+		Logger(ctx).Failed(
+			LogEvent("lpc idempotency"),
+			LogProcessor(k),
+			LogError(err),
+		)
+	}
+
+	return res
+}
+
+// idempotentCall 未携带key或未配置redis时直接调用
+func idempotentCall(ctx context.Context, req *Req, call func(ctx context.Context, req *Req) *Res) *Res {
+	key := GetIdempotencyKey(ctx)
+	cli := getIdemCli()
+	if key == "" || cli == nil {
+		return call(ctx, req)
+	}
+
+	k := idemKey(ctx, req.GetApp(), req.GetMethod(), key)
+	hash := paramHash(req)
+	pending := MustJsonMarshal(idemRecord{State: idemPending, Hash: hash})
+
+	deadline := time.Now().Add(IdempotencyWait)
+	for {
+		ok, err := cli.SetNX(ctx, k, pending, IdempotencyLock).Result()
+		if err != nil {
+			return call(ctx, req)
+		}
+
+		if ok {
+			return firstCall(ctx, cli, k, hash, pending, req, call)
+		}
+
+		record, err := loadIdem(ctx, cli, k)
+		if err != nil && !IgnoreRedisErr(err) {
+			return call(ctx, req)
+		}
+
+		//占位已过期时重新抢占
+		expired := err != nil
+		if !expired && record.Hash != hash {
+			return IdempotencyMismatchRes(key)
+		}
+
+		if !expired && record.State == idemDone && record.Res != nil {
+			return record.Res
+		}
+
+		if time.Now().After(deadline) {
+			return ConflictRes(key)
+		}
+
+		if expired {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ConflictRes(key)
+		case <-time.After(IdempotencyPoll):
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	. "mykit/core/dsp"
+	. "mykit/core/types"
+	"reflect"
+	"sync"
+)
+
+type LpcInvoker func(ctx context.Context, req *Req) (res *Res, err error)
+
+// LpcInterceptor 包裹handler执行, 不调用next并返回*ErrorCode即可短路
+type LpcInterceptor func(ctx context.Context, req *Req, meta LpcMeta, next LpcInvoker) (res *Res, err error)
+
+var (
+	interceptorLock sync.RWMutex
+	lpcInterceptors []LpcInterceptor
+)
+
+func UseLpcInterceptor(i ...LpcInterceptor) {
+	interceptorLock.Lock()
+	lpcInterceptors = append(lpcInterceptors, i...)
+	interceptorLock.Unlock()
+}
+
+// globalInterceptors 返回副本, 与UseLpcInterceptor并发时不共享底层数组
+func globalInterceptors() []LpcInterceptor {
+	interceptorLock.RLock()
+	defer interceptorLock.RUnlock()
+
+	return append([]LpcInterceptor{}, lpcInterceptors...)
+}
+
+func (t LpcMeta) Name() string {
+	return t.name
+}
+
+func (t LpcMeta) Param() reflect.Type {
+	return t.param
+}
+
+func (t LpcMeta) Out() reflect.Type {
+	if t.item != nil {
+		return t.item
+	}
+
+	h := reflect.ValueOf(t.Handler)
+	return h.Method(t.n).Type().Out(0)
+}
+
+func chainLpcInterceptor(meta LpcMeta, final LpcInvoker, i ...[]LpcInterceptor) LpcInvoker {
+	all := []LpcInterceptor{}
+	for _, v := range i {
+		all = append(all, v...)
+	}
+
+	res := final
+	for k := len(all) - 1; k >= 0; k-- {
+		interceptor := all[k]
+		next := res
+
+		res = func(ctx context.Context, req *Req) (*Res, error) {
+			return interceptor(ctx, req, meta, next)
+		}
+	}
+
+	return res
+}
+
+func ShortCircuitRes(method string, err error) *Res {
+	v, ok := err.(*ErrorCode)
+	if ok {
+		res := &Res{
+			Code: v.Code(),
+			Msg:  v.Error(),
+			Data: ByteOfNullJson,
+		}
+
+		return res
+	}
+
+	res := &Res{
+		Code: CodeInternal,
+		Msg:  fmt.Sprintf(callFailed, method),
+		Data: ByteOfNullJson,
+	}
+
+	return res
+}
@@ -11,28 +11,67 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-type LpcDispatch map[string]*Lpc
+// LpcDispatch 各实例独立持有已注册的app与运行时开关
+type LpcDispatch struct {
+	sync.RWMutex
+	apps map[string]*Lpc
+	off  LpcSwitch
+}
 
 var (
-	DISP = LpcDispatch{}
+	DISP = NewLpcDispatch()
 )
 
-func (t LpcDispatch) getLpc(app string) *Lpc {
-	lpc, ok := t[app]
+func NewLpcDispatch() *LpcDispatch {
+	res := &LpcDispatch{
+		apps: map[string]*Lpc{},
+		off:  NewLpcSwitch(),
+	}
+
+	return res
+}
+
+func (t *LpcDispatch) getLpc(app string) *Lpc {
+	t.Lock()
+	defer t.Unlock()
+
+	lpc, ok := t.apps[app]
 	if !ok || lpc == nil {
 		lpc = NewLpc(app)
-		t[app] = lpc
+		t.apps[app] = lpc
 	}
 
 	return lpc
 }
 
-func (t LpcDispatch) Add(app string, f ...Handler) {
+func (t *LpcDispatch) Get(app string) (lpc *Lpc, ok bool) {
+	t.RLock()
+	lpc, ok = t.apps[app]
+	t.RUnlock()
+
+	return lpc, ok && lpc != nil
+}
+
+func (t *LpcDispatch) App() []string {
+	t.RLock()
+	app := []string{}
+	for k := range t.apps {
+		app = append(app, k)
+	}
+	t.RUnlock()
+
+	sort.Strings(app)
+
+	return app
+}
+
+func (t *LpcDispatch) Add(app string, f ...Handler) {
 	lpc := t.getLpc(app)
 
 	for _, v := range f {
@@ -40,7 +79,7 @@ func (t LpcDispatch) Add(app string, f ...Handler) {
 	}
 }
 
-func (t LpcDispatch) Override(app string, f ...Handler) {
+func (t *LpcDispatch) Override(app string, f ...Handler) {
 	lpc := t.getLpc(app)
 
 	for _, v := range f {
@@ -48,52 +87,85 @@ func (t LpcDispatch) Override(app string, f ...Handler) {
 	}
 }
 
-func (t LpcDispatch) Impact(app string, imp ...Impactor) {
+func (t *LpcDispatch) Impact(app string, imp ...Impactor) {
 	lpc := t.getLpc(app)
 
 	lpc.Impact(imp...)
 }
 
-func (t LpcDispatch) Forbid(app string, method ...string) {
+func (t *LpcDispatch) Use(app string, i ...LpcInterceptor) {
+	lpc := t.getLpc(app)
+
+	lpc.Use(i...)
+}
+
+func (t *LpcDispatch) Forbid(app string, method ...string) {
 	lpc := t.getLpc(app)
 
 	lpc.Forbid(method...)
 }
 
-func (t LpcDispatch) ForbidApp(app ...string) {
+func (t *LpcDispatch) ForbidApp(app ...string) {
+	t.Lock()
 	for _, v := range app {
-		delete(t, v)
+		delete(t.apps, v)
 	}
+	t.Unlock()
 }
 
-func (t LpcDispatch) Call(ctx context.Context, input *Req, output *Res) (err error) {
-	ctx = HandleMd(ctx)
-	ctx = context.WithValue(ctx, TagMethod, input.GetMethod())
+func (t *LpcDispatch) Call(ctx context.Context, input *Req, output *Res) (err error) {
+	ctx, cancel := WithMdDeadline(HandleMd(ctx))
+	defer cancel()
 
 	app := input.GetApp()
 
-	lpc, ok := t[app]
+	//入站user/role可被伪造, 由rbacCheck据此拒绝
+	ctx = trustMd(ctx, app, input.GetMethod())
+
+	lpc, ok := t.Get(app)
 	if !ok {
 		AppNotImplRes(app).CloneTo(output)
 		return
 	}
 
-	lpcRes, _ := lpcCall(lpc, ctx, input)
+	ctx, input = lpc.resolveReq(ctx, input)
+
+	if t.Disabled(app, input.GetMethod()) {
+		DisabledRes(app, input.GetMethod()).CloneTo(output)
+		return
+	}
+
+	deny := rbacCheck(ctx, app, input.GetMethod())
+	if deny != nil {
+		deny.CloneTo(output)
+		return
+	}
+
+	//上游已放弃的请求不再执行
+	if ctx.Err() != nil {
+		TimeoutRes(input.GetMethod()).CloneTo(output)
+		return
+	}
+
+	lpcRes := idempotentCall(ctx, input, func(ctx context.Context, req *Req) *Res {
+		res, _ := lpcCall(lpc, ctx, req)
+		return res
+	})
 	lpcRes.CloneTo(output)
 
 	return
 }
 
-func (t LpcDispatch) Log() {
-	app := []string{}
-	for k := range t {
-		app = append(app, k)
-	}
-	sort.Strings(app)
-
+func (t *LpcDispatch) Log() {
 	iLog := []string{}
-	for _, v := range app {
-		a, o := t[v].Abstract()
+	for _, v := range t.App() {
+		lpc, ok := t.Get(v)
+		if !ok {
+			continue
+		}
+
+		a, o := lpc.Abstract()
+		p := lpc.Policies()
 
 		LogS1.Info(LogMsgSetup,
 			LogEvent("lpc"),
@@ -101,6 +173,7 @@ func (t LpcDispatch) Log() {
 			LogDetail(map[string]interface{}{
 				logImport:   a,
 				logOverride: o,
+				logPolicy:   p,
 			}),
 		)
 
@@ -111,6 +184,8 @@ func (t LpcDispatch) Log() {
 		for _, v2 := range o {
 			iLog = append(iLog, ImportMsg(1, v, v2))
 		}
+
+		iLog = append(iLog, policyLines(v, p)...)
 	}
 
 	if len(iLog) > 0 {
@@ -118,44 +193,76 @@ func (t LpcDispatch) Log() {
 	}
 }
 
-func (t LpcDispatch) IgnoreLog(app string, f ...string) {
-	lpc, ok := t[app]
-	if !ok || lpc == nil {
+func (t *LpcDispatch) IgnoreLog(app string, f ...string) {
+	lpc, ok := t.Get(app)
+	if !ok {
 		return
 	}
 
 	lpc.SetLogOut(-9, f...)
 }
 
-func (t LpcDispatch) IgnoreLogData(app string, f ...string) {
-	lpc, ok := t[app]
-	if !ok || lpc == nil {
+func (t *LpcDispatch) IgnoreLogData(app string, f ...string) {
+	lpc, ok := t.Get(app)
+	if !ok {
 		return
 	}
 
 	lpc.SetLogOut(-1, f...)
 }
 
-func (t LpcDispatch) Method() map[string][]string {
+func (t *LpcDispatch) Method() map[string][]string {
 	res := map[string][]string{}
 
-	for k, v := range t {
-		m := v.Method()
-		res[k] = append(res[k], m...)
+	for _, k := range t.App() {
+		lpc, ok := t.Get(k)
+		if !ok {
+			continue
+		}
+
+		res[k] = append(res[k], lpc.Method()...)
 	}
 
 	return res
 }
 
 type LpcMeta struct {
-	o     int
-	n     int
-	param reflect.Type
-	out   int32
-	name  string
+	o      int
+	n      int
+	param  reflect.Type
+	out    int32
+	name   string
+	stream int8
+	item   reflect.Type
+
+	version    int
+	deprecated bool
+	policy     MethodPolicy
 	Handler
 }
 
+func newLpcMeta(name string, n int, h Handler, mt reflect.Type) LpcMeta {
+	res := LpcMeta{
+		name:    name,
+		n:       n,
+		Handler: h,
+		param:   mt.In(1).Elem(),
+		out:     10,
+		version: 1,
+		policy:  handlerPolicy(h, name),
+	}
+
+	if res.policy.Out != LogOutDefault {
+		res.out = int32(res.policy.Out)
+	}
+
+	if IsStreamMethod(mt) {
+		res.stream, res.item = parseSender(mt.In(2))
+	}
+
+	return res
+}
+
 func (t LpcMeta) ImportMsg(app string) string {
 	return ImportMsg(t.o, app, t.name)
 }
@@ -188,15 +295,7 @@ func ParseLpcMeta(h Handler, method ...string) (res []LpcMeta) {
 			continue
 		}
 
-		item := LpcMeta{
-			name:    v,
-			n:       n,
-			Handler: h,
-			param:   mt.In(1).Elem(),
-			out:     10,
-		}
-
-		res = append(res, item)
+		res = append(res, newLpcMeta(v, i, h, mt))
 	}
 
 	return
@@ -251,6 +350,10 @@ var (
 
 var (
 	DefaultMethodCheck = func(m reflect.Type) (code int32) {
+		if IsStreamMethod(m) {
+			return checkStreamMethod(m)
+		}
+
 		//校验入参出参数量
 		code++
 		if m.NumIn() != 2 || m.NumOut() != 2 {
@@ -318,15 +421,7 @@ func parseLpcMeta(h Handler, n int) (meta LpcMeta) {
 		return
 	}
 
-	meta = LpcMeta{
-		name:    name,
-		n:       n,
-		Handler: h,
-		param:   method.In(1).Elem(),
-		out:     10,
-	}
-
-	return
+	return newLpcMeta(name, n, h, method)
 }
 
 func InitLpc(release bool) {
@@ -342,25 +437,84 @@ func InitLpc(release bool) {
 	}
 }
 
+// IsolateLpc 清空全局拦截器、缓存、幂等与RBAC配置, restore恢复原值, 用于测试隔离
+func IsolateLpc() (restore func()) {
+	interceptorLock.Lock()
+	cacheLock.Lock()
+	idemLock.Lock()
+	rbacLock.Lock()
+	defer rbacLock.Unlock()
+	defer idemLock.Unlock()
+	defer cacheLock.Unlock()
+	defer interceptorLock.Unlock()
+
+	i, cc, cr, ic := lpcInterceptors, cacheCli, cacheRules, idemCli
+	r, rp, ms := RBAC, rbacPerm, mdSecret
+
+	lpcInterceptors, cacheCli, cacheRules, idemCli = nil, nil, map[string]CacheRule{}, nil
+	RBAC, rbacPerm, mdSecret = nil, map[string]string{}, nil
+
+	restore = func() {
+		interceptorLock.Lock()
+		cacheLock.Lock()
+		idemLock.Lock()
+		rbacLock.Lock()
+		defer rbacLock.Unlock()
+		defer idemLock.Unlock()
+		defer cacheLock.Unlock()
+		defer interceptorLock.Unlock()
+
+		lpcInterceptors, cacheCli, cacheRules, idemCli = i, cc, cr, ic
+		RBAC, rbacPerm, mdSecret = r, rp, ms
+	}
+
+	return
+}
+
 type Lpc struct {
+	sync.RWMutex
 	app string
 	f   map[string]LpcMeta
+	i   []LpcInterceptor
+	v   map[string]int       //方法名 -> 已注册的最高版本
+	w   map[string]time.Time //方法 -> 上次弃用告警时间
+	d   map[string]int       //方法名 -> 未带版本的请求使用的版本
 }
 
 func NewLpc(app string) *Lpc {
 	res := &Lpc{
 		app: app,
 		f:   map[string]LpcMeta{},
+		v:   map[string]int{},
+		w:   map[string]time.Time{},
+		d:   map[string]int{},
 	}
 
 	return res
 }
 
+func (t *Lpc) Use(i ...LpcInterceptor) {
+	t.RWMutex.Lock()
+	t.i = append(t.i, i...)
+	t.RWMutex.Unlock()
+}
+
+func (t *Lpc) Meta(method string) (meta LpcMeta, ok bool) {
+	t.RWMutex.RLock()
+	meta, ok = t.f[method]
+	t.RWMutex.RUnlock()
+
+	return
+}
+
 func (t *Lpc) Method() []string {
+	t.RWMutex.RLock()
 	m := []string{}
 	for k := range t.f {
 		m = append(m, k)
 	}
+	t.RWMutex.RUnlock()
+
 	sort.Strings(m)
 
 	return m
@@ -373,7 +527,11 @@ func (t *Lpc) Abstract() (a, o []string) {
 	m := t.Method()
 
 	for _, v := range m {
-		meta := t.f[v]
+		meta, ok := t.Meta(v)
+		if !ok {
+			continue
+		}
+
 		if meta.o == 0 {
 			a = append(a, meta.name)
 		} else {
@@ -442,15 +600,7 @@ func (t *Lpc) parseMeta(k int, h Handler, n int) LpcMeta {
 		return LpcMeta{}
 	}
 
-	meta := LpcMeta{
-		name:    name,
-		n:       n,
-		Handler: h,
-		param:   method.In(1).Elem(),
-		out:     10,
-	}
-
-	return meta
+	return newLpcMeta(name, n, h, method)
 }
 
 func (t *Lpc) Reg(meta LpcMeta) (res string) {
@@ -459,18 +609,28 @@ func (t *Lpc) Reg(meta LpcMeta) (res string) {
 		HandleInitErr(msg, ErrInvalidParam)
 	}
 
+	t.RWMutex.Lock()
 	_, ok := t.f[meta.name]
+	if !ok {
+		t.f[meta.name] = meta
+		t.latest(meta.name)
+	}
+	t.RWMutex.Unlock()
+
 	if ok {
 		msg := fmt.Sprintf(dupedLpcMeta, meta.name, t.app)
 		HandleInitErr(msg, ErrInvalidParam)
 	}
 
-	t.f[meta.name] = meta
+	t.applyPolicy(meta)
 
 	return meta.ImportMsg(t.app)
 }
 
 func (t *Lpc) Override(meta ...LpcMeta) {
+	t.RWMutex.Lock()
+	defer t.RWMutex.Unlock()
+
 	for _, v := range meta {
 		if v.name == "" {
 			continue
@@ -482,6 +642,8 @@ func (t *Lpc) Override(meta ...LpcMeta) {
 		}
 
 		t.f[v.name] = v
+		t.latest(v.name)
+		t.applyPolicy(v)
 	}
 }
 
@@ -503,9 +665,12 @@ func (t *Lpc) Impact(imp ...Impactor) {
 }
 
 func (t *Lpc) Forbid(method ...string) {
+	t.RWMutex.Lock()
 	for _, v := range method {
 		delete(t.f, v)
+		t.latest(v)
 	}
+	t.RWMutex.Unlock()
 }
 
 func (t *Lpc) IgnoreLog(f ...string) {
@@ -513,6 +678,9 @@ func (t *Lpc) IgnoreLog(f ...string) {
 }
 
 func (t *Lpc) SetLogOut(n int32, f ...string) {
+	t.RWMutex.Lock()
+	defer t.RWMutex.Unlock()
+
 	for _, v := range f {
 		meta, ok := t.f[v]
 		if ok {
@@ -524,7 +692,7 @@ func (t *Lpc) SetLogOut(n int32, f ...string) {
 
 func (t *Lpc) GetMethod(ctx context.Context, method string) (
 	f reflect.Value, in reflect.Type, ok bool) {
-	fn, ok := t.f[method]
+	fn, ok := t.Meta(method)
 	if !ok {
 		return reflect.Value{}, nil, false
 	}
@@ -538,30 +706,61 @@ func (t *Lpc) GetMethod(ctx context.Context, method string) (
 }
 
 func (t *Lpc) Call(ctx context.Context, req *Req) (res *Res, err error) {
-	code, msg, data, err := t.call(ctx, req)
+	method := req.GetMethod()
+	meta, ok := t.lookup(ctx, method)
+	if !ok {
+		res = MethodNotImplRes(method)
+		return
+	}
+
+	if meta.Stream() {
+		res = StreamOnlyRes(method)
+		return
+	}
+
+	if !meta.policy.allow(ctx) {
+		res = RoleRequiredRes(method, meta.policy.Role)
+		return
+	}
+
+	ctx, cancel := meta.policy.withTimeout(ctx)
+	defer cancel()
+
+	t.RWMutex.RLock()
+	i := t.i
+	t.RWMutex.RUnlock()
 
-	res = &Res{Code: code, Msg: msg, Data: EnsureJsonByte(data)}
+	var final LpcInvoker = func(ctx context.Context, req *Req) (*Res, error) {
+		return t.cacheCall(ctx, req, func(ctx context.Context, req *Req) (*Res, error) {
+			code, msg, data, err := t.call(ctx, meta, req)
+			if code != http.StatusOK || IsJsonEncoder(GetCodec(ctx)) {
+				data = EnsureJsonByte(data)
+			}
+
+			return &Res{Code: code, Msg: msg, Data: data}, err
+		})
+	}
+
+	invoker := chainLpcInterceptor(meta, final, globalInterceptors(), i)
+
+	res, err = invoker(ctx, req)
+	if res == nil {
+		res = ShortCircuitRes(method, err)
+	}
 
 	return
 }
 
-func (t *Lpc) call(ctx context.Context, req *Req) (
-	code int32, msg string, data []byte, err error) {
-	method := req.GetMethod()
-	f, in, ok := t.GetMethod(ctx, method)
-	if !ok {
-		code = CodeUnimplemented
-		msg = fmt.Sprintf(methodNotImpl, method)
-		return
-	}
+func parseParam(enc Encoder, meta LpcMeta, req *Req) (
+	param interface{}, code int32, msg string, err error) {
+	in := meta.param
 
 	//解析入参
-	payload := req.Payload()
-	param := reflect.New(in).Interface()
-	err = UnmarshalJson(payload, param)
+	param = reflect.New(in).Interface()
+	err = decodeParam(enc, req, param)
 	if err != nil {
 		code = CodeInvalidArgument
-		msg = parseDecodeErr(payload, err)
+		msg = parseDecodeErr(req.Payload(), err)
 		return
 	}
 
@@ -575,6 +774,21 @@ func (t *Lpc) call(ctx context.Context, req *Req) (
 		}
 	}
 
+	return
+}
+
+func (t *Lpc) call(ctx context.Context, meta LpcMeta, req *Req) (
+	code int32, msg string, data []byte, err error) {
+	method := req.GetMethod()
+	h := meta.New(ctx)
+	f := reflect.ValueOf(h).Method(meta.n)
+	enc := GetCodec(ctx)
+
+	param, code, msg, err := parseParam(enc, meta, req)
+	if err != nil {
+		return
+	}
+
 	//执行方法
 	callRes := f.Call([]reflect.Value{
 		reflect.ValueOf(ctx),
@@ -582,7 +796,7 @@ func (t *Lpc) call(ctx context.Context, req *Req) (
 	})
 
 	//处理执行结果
-	err, ok = callRes[1].Interface().(error)
+	err, ok := callRes[1].Interface().(error)
 	if ok && err != nil {
 		var v *ErrorCode
 		v, ok = err.(*ErrorCode)
@@ -598,9 +812,16 @@ func (t *Lpc) call(ctx context.Context, req *Req) (
 		return
 	}
 
+	data, err = encodeData(enc, callRes[0])
+	if err != nil {
+		code = CodeInternal
+		msg = fmt.Sprintf(callFailed, method)
+		data = nil
+		return
+	}
+
 	code = http.StatusOK
 	msg = RspMsgSuccess
-	data = RespJsonMarshalValue(callRes[0])
 
 	return
 }
@@ -609,7 +830,8 @@ func callDev(lpc *Lpc, ctx context.Context, req *Req) (res *Res, err error) {
 	var cost time.Duration
 
 	method := req.GetMethod()
-	out := lpc.f[method].out
+	meta, _ := lpc.Meta(method)
+	out := meta.out
 	user := GetUser(ctx)
 	from := GetFrom(ctx)
 
@@ -628,8 +850,11 @@ func callDev(lpc *Lpc, ctx context.Context, req *Req) (res *Res, err error) {
 				panicErr = fmt.Errorf("%v", r)
 			}
 
-			res.Code = CodeInternal
-			res.Msg = fmt.Sprintf(callFailed, method)
+			res = &Res{
+				Code: CodeInternal,
+				Msg:  fmt.Sprintf(callFailed, method),
+				Data: ByteOfNullJson,
+			}
 		}
 
 		if failed {
@@ -650,11 +875,11 @@ func callDev(lpc *Lpc, ctx context.Context, req *Req) (res *Res, err error) {
 			f = append(f, LogBinary(res.GetData()))
 		}
 
-		if cost > rpcQuerySlowThreshold {
+		if cost > meta.policy.slow() {
 			f = append(f, LogPerformanceSlow())
 		}
 
-		Logger(ctx).Output(method, err, f...)
+		outputLpc(ctx, meta.policy.Level, method, err, f...)
 	}()
 
 	Logger(ctx).Info(method,
@@ -684,7 +909,8 @@ func callRelease(lpc *Lpc, ctx context.Context, req *Req) (res *Res, err error)
 	var cost time.Duration
 
 	method := req.GetMethod()
-	out := lpc.f[method].out
+	meta, _ := lpc.Meta(method)
+	out := meta.out
 	user := GetUser(ctx)
 	from := GetFrom(ctx)
 
@@ -703,8 +929,11 @@ func callRelease(lpc *Lpc, ctx context.Context, req *Req) (res *Res, err error)
 				panicErr = fmt.Errorf("%v", r)
 			}
 
-			res.Code = CodeInternal
-			res.Msg = fmt.Sprintf(callFailed, method)
+			res = &Res{
+				Code: CodeInternal,
+				Msg:  fmt.Sprintf(callFailed, method),
+				Data: ByteOfNullJson,
+			}
 		}
 
 		if failed {
@@ -725,11 +954,11 @@ func callRelease(lpc *Lpc, ctx context.Context, req *Req) (res *Res, err error)
 			f = append(f, LogBinary(res.GetData()))
 		}
 
-		if cost > rpcQuerySlowThreshold {
+		if cost > meta.policy.slow() {
 			f = append(f, LogPerformanceSlow())
 		}
 
-		Logger(ctx).Output(method, err, f...)
+		outputLpc(ctx, meta.policy.Level, method, err, f...)
 	}()
 
 	if out > 0 {
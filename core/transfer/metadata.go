@@ -12,7 +12,7 @@ import (
 )
 
 var (
-	p1 = []string{HeaderClient, HeaderUa, HeadFrom, HeadScn, HeadUser, HeadCredential, HeadLanguage}
+	p1 = []string{HeaderClient, HeaderUa, HeadFrom, HeadScn, HeadUser, HeadCredential, HeadLanguage, HeadCodec, HeadIdempotency, HeadRole}
 )
 
 func MetaFromGin(c *gin.Context) map[string]string {
@@ -28,6 +28,17 @@ func MetaFromGin(c *gin.Context) map[string]string {
 		TagLanguage:   c.GetString(TagLanguage),
 	}
 
+	//角色由鉴权中间件写入gin context, 不信任客户端header
+	role := c.GetString(TagRole)
+	if role != "" {
+		res[TagRole] = role
+	}
+
+	key := c.GetHeader(HeadIdempotencyKey)
+	if key != "" {
+		res[TagIdempotency] = key
+	}
+
 	return res
 }
 
@@ -41,7 +52,7 @@ func RpcCtx(ctx context.Context) context.Context {
 		TagClient:    PidStr(),
 		TagUserAgent: "smarter rpc",
 		TagFrom:      INST(),
-		TagUser:      "@#smarter#@",
+		TagUser:      rpcUser,
 	}
 
 	trace := GetTrace(ctx)
@@ -58,6 +69,11 @@ func RpcCtx(ctx context.Context) context.Context {
 
 	md[TagLanguage] = GetLanguage(ctx)
 
+	timeout := remainTimeout(ctx)
+	if timeout != "" {
+		md[TagTimeout] = timeout
+	}
+
 	return NewMetaContext(ctx, md)
 }
 
@@ -0,0 +1,80 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	. "mykit/core/dsp"
+	"net/http"
+)
+
+// Method 绑定app/method的强类型rpc调用描述
+type Method[In, Out any] struct {
+	App    string
+	Method string
+	codec  string
+	call   Caller
+}
+
+func NewMethod[In, Out any](call Caller, app, method string) Method[In, Out] {
+	res := Method[In, Out]{
+		App:    app,
+		Method: method,
+		call:   call,
+	}
+
+	return res
+}
+
+func (t Method[In, Out]) Name() string {
+	return t.App + "." + t.Method
+}
+
+// Codec 返回使用指定codec编码Param/Data的副本
+func (t Method[In, Out]) Codec(codec string) Method[In, Out] {
+	t.codec = codec
+	return t
+}
+
+func (t Method[In, Out]) Req(in In) (*Req, error) {
+	return NewReqWithCodec(t.App, t.Method, in, t.codec)
+}
+
+// Call 非200的Res.Code映射为*ErrorCode, 此时out为零值
+func (t Method[In, Out]) Call(ctx context.Context, in In) (out Out, err error) {
+	if t.call == nil {
+		err = NewErrorCode(fmt.Sprintf(appNotImpl, t.App), CodeUnimplemented)
+		return
+	}
+
+	req, err := t.Req(in)
+	if err != nil {
+		err = NewErrorCode(err.Error(), CodeInvalidArgument)
+		return
+	}
+
+	rsp, err := t.call(WithCodec(RpcCtx(ctx), t.codec), req)
+	if err != nil {
+		return
+	}
+
+	err = rsp.Err()
+	if err != nil {
+		return
+	}
+
+	out, err = DecodeData[Out](GetEncoder(t.codec), rsp.GetData())
+
+	return
+}
+
+func (x *Res) Err() error {
+	if x == nil {
+		return ErrRpcFailed
+	}
+
+	if x.GetCode() == http.StatusOK {
+		return nil
+	}
+
+	return NewErrorCode(x.GetMsg(), x.GetCode())
+}
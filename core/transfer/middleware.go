@@ -37,10 +37,16 @@ var defaultCorsAllowHeaders = []string{
 	HeadXToken,
 	HeadAToken,
 	HeadLanguage,
+	HeadCodec,
+	HeadTimeout,
+	HeadVersion,
+	HeadIdempotencyKey,
 	HeaderClient,
+	HeadClientId,
 	HeadSession,
 	HeadSecret,
 	HeadNonce,
+	HeadTs,
 	HeadWxApp,
 }
 
@@ -148,6 +154,51 @@ func SimpleToken(raw string) gin.HandlerFunc {
 	return res
 }
 
+// AuthInfo 鉴权结果, 写入gin context后由MetaFromGin转发, RBAC与websocket据此识别用户
+type AuthInfo struct {
+	User       string   `json:"user"`
+	Tenant     string   `json:"tenant,omitempty"`
+	Role       []string `json:"role,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+	Exp        int64    `json:"exp,omitempty"` //过期时间, unix秒
+}
+
+func (t AuthInfo) Expired() bool {
+	return t.Exp > 0 && time.Now().Unix() > t.Exp
+}
+
+// Authenticator 由业务实现, 校验请求中的凭证
+type Authenticator func(c *gin.Context) (AuthInfo, error)
+
+// Authenticate auth为nil或校验失败时返回401
+func Authenticate(auth Authenticator) gin.HandlerFunc {
+	var h = func(c *gin.Context) {
+		info := AuthInfo{}
+		err := ErrInvalidParam
+		if auth != nil {
+			info, err = auth(c)
+		}
+
+		if err != nil || info.User == "" || info.Expired() {
+			c.JSON(
+				http.StatusUnauthorized,
+				NewFinalRsp("permission deny 0005", http.StatusUnauthorized),
+			)
+			c.Abort()
+			return
+		}
+
+		c.Set(TagUser, info.User)
+		c.Set(TagTenant, info.Tenant)
+		c.Set(TagRole, strings.Join(info.Role, ","))
+		c.Set(TagCredential, info.Credential)
+
+		c.Next()
+	}
+
+	return h
+}
+
 func SetFailedCode(c *gin.Context, code uint) {
 	c.Set(LogFiledCode, int(code)*-1)
 }
@@ -213,7 +264,16 @@ func TraceMiddle(c *gin.Context) {
 	c.Next()
 }
 
+// GinHeartbeat 可作为健康检查, 未初始化或退出中返回503
 func GinHeartbeat(c *gin.Context) {
+	if !INITED() {
+		c.JSON(
+			http.StatusServiceUnavailable,
+			NewFinalRsp(notReady, http.StatusServiceUnavailable),
+		)
+		return
+	}
+
 	res := map[string]int64{
 		"tick": time.Now().UnixMilli(),
 	}
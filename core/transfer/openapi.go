@@ -0,0 +1,211 @@
+package transfer
+
+import (
+	"fmt"
+	. "mykit/core/dsp"
+	. "mykit/core/types"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	openApiVersion = "3.1.0"
+	apiPathPat     = "%v/%v/%v"
+)
+
+type UrlDescriber func(path string) string
+
+type OpenApiDoc struct {
+	OpenApi string                            `json:"openapi"`
+	Info    OpenApiInfo                       `json:"info"`
+	Paths   map[string]map[string]OpenApiOper `json:"paths"`
+}
+
+type OpenApiInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenApiOper struct {
+	OperationId string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Tags        []string                   `json:"tags"`
+	RequestBody OpenApiBody                `json:"requestBody"`
+	Responses   map[string]OpenApiResponse `json:"responses"`
+}
+
+type OpenApiBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenApiMediaType `json:"content"`
+}
+
+type OpenApiResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenApiMediaType `json:"content"`
+}
+
+type OpenApiMediaType struct {
+	Schema *JsonSchema `json:"schema"`
+}
+
+type MethodSchema struct {
+	App    string      `json:"app"`
+	Method string      `json:"method"`
+	Desc   string      `json:"desc,omitempty"`
+	Stream bool        `json:"stream,omitempty"`
+	Input  *JsonSchema `json:"input"`
+	Output *JsonSchema `json:"output"`
+}
+
+func ApiPath(prefix, app, method string) string {
+	return fmt.Sprintf(apiPathPat, prefix, app, method)
+}
+
+func (t LpcMeta) Schema(app string) MethodSchema {
+	res := MethodSchema{
+		App:    app,
+		Method: t.name,
+		Stream: t.Stream(),
+		Input:  NewJsonSchema(t.Param(), t.name),
+		Output: NewJsonSchema(t.Out()),
+	}
+
+	return res
+}
+
+func (t *Lpc) Schema() []MethodSchema {
+	res := []MethodSchema{}
+
+	for _, v := range t.Method() {
+		meta, ok := t.Meta(v)
+		if !ok {
+			continue
+		}
+
+		res = append(res, meta.Schema(t.app))
+	}
+
+	return res
+}
+
+func (t *LpcDispatch) Schema(app, method string) (res MethodSchema, ok bool) {
+	lpc, ok := t.Get(app)
+	if !ok {
+		return
+	}
+
+	meta, ok := lpc.Meta(method)
+	if !ok {
+		return
+	}
+
+	return meta.Schema(app), true
+}
+
+func (t *LpcDispatch) OpenApi(prefix string, desc UrlDescriber) OpenApiDoc {
+	res := OpenApiDoc{
+		OpenApi: openApiVersion,
+		Info: OpenApiInfo{
+			Title:   DeStrParam(INST(), "smarter"),
+			Version: DeStrParam(VERSION(), "0.0"),
+		},
+		Paths: map[string]map[string]OpenApiOper{},
+	}
+
+	for _, v := range t.App() {
+		lpc, ok := t.Get(v)
+		if !ok {
+			continue
+		}
+
+		for _, s := range lpc.Schema() {
+			path := ApiPath(prefix, v, s.Method)
+			res.Paths[path] = map[string]OpenApiOper{
+				"post": s.operation(path, desc),
+			}
+		}
+	}
+
+	return res
+}
+
+func (t MethodSchema) operation(path string, desc UrlDescriber) OpenApiOper {
+	summary := ""
+	if desc != nil {
+		summary = desc(path)
+		if summary == path {
+			summary = ""
+		}
+	}
+
+	t.Input.Schema = ""
+	t.Output.Schema = ""
+
+	envelope := &JsonSchema{
+		Type: "object",
+		Properties: map[string]*JsonSchema{
+			"code": {Type: "integer"},
+			"msg":  {Type: "string"},
+			"data": t.Output,
+		},
+		Required: []string{"code", "msg", "data"},
+	}
+
+	media := ApplicationJson
+	if t.Stream {
+		media = ApplicationJsonLine
+	}
+
+	res := OpenApiOper{
+		OperationId: t.App + "." + t.Method,
+		Summary:     summary,
+		Tags:        []string{t.App},
+		RequestBody: OpenApiBody{
+			Required: true,
+			Content: map[string]OpenApiMediaType{
+				ApplicationJson: {Schema: t.Input},
+			},
+		},
+		Responses: map[string]OpenApiResponse{
+			"200": {
+				Description: RspMsgSuccess,
+				Content: map[string]OpenApiMediaType{
+					media: {Schema: envelope},
+				},
+			},
+		},
+	}
+
+	return res
+}
+
+func (t *LpcDispatch) OpenApiHandler(prefix string, desc UrlDescriber) func(c *gin.Context) {
+	var h = func(c *gin.Context) {
+		c.JSON(http.StatusOK, t.OpenApi(prefix, desc))
+	}
+
+	return h
+}
+
+func (t *LpcDispatch) JsonSchemaHandler(prefix string, desc UrlDescriber) func(c *gin.Context) {
+	var h = func(c *gin.Context) {
+		app := c.Param(TagApp)
+		method := c.Param(TagMethod)
+
+		res, ok := t.Schema(app, method)
+		if !ok {
+			rsp := MethodNotImplRes(method)
+			NewFinalRsp2(rsp.Msg, rsp.Code).Send(c)
+			return
+		}
+
+		if desc != nil {
+			res.Desc = desc(ApiPath(prefix, app, method))
+		}
+
+		SuccessFinalRsp2(res).Send(c)
+	}
+
+	return h
+}
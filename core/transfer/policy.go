@@ -0,0 +1,192 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	. "mykit/core/dsp"
+	. "mykit/core/types"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 方法策略由handler集中声明, 注册时生效, 替代分散的IgnoreLog/Cache/Idempotent等调用
+
+type LogOut int32
+
+const (
+	LogOutDefault LogOut = 0
+	LogOutAll     LogOut = 10
+	LogOutNoData  LogOut = -1
+	LogOutNone    LogOut = -9
+)
+
+type MethodPolicy struct {
+	Timeout    time.Duration //执行超时, 与上游deadline取较早者
+	Slow       time.Duration //慢调用阈值, 缺省rpcQuerySlowThreshold
+	Level      zapcore.Level //成功调用的日志级别, 缺省Info
+	Out        LogOut
+	Role       []string      //满足其一即可调用
+	Perm       string        //RBAC所需权限, 缺省PolicyWrite
+	Idempotent bool          //经PublishIdempotent发布后调用方才会重试
+	Cache      time.Duration //>0时缓存结果
+	CacheTags  []string
+}
+
+// PolicyHandler 可选实现, key为方法名(首字母小写)
+type PolicyHandler interface {
+	Policies() map[string]MethodPolicy
+}
+
+func handlerPolicy(h Handler, name string) (res MethodPolicy) {
+	p, ok := h.(PolicyHandler)
+	if !ok {
+		return
+	}
+
+	return p.Policies()[name]
+}
+
+func (t MethodPolicy) IsZero() bool {
+	return len(t.Abstract()) == 0
+}
+
+func (t MethodPolicy) Abstract() []string {
+	res := []string{}
+
+	if t.Timeout > 0 {
+		res = append(res, "timeout="+t.Timeout.String())
+	}
+
+	if t.Slow > 0 {
+		res = append(res, "slow="+t.Slow.String())
+	}
+
+	if t.Level != zapcore.InfoLevel {
+		res = append(res, "level="+t.Level.String())
+	}
+
+	switch t.Out {
+	case LogOutAll:
+		res = append(res, "out=all")
+	case LogOutNoData:
+		res = append(res, "out=noData")
+	case LogOutNone:
+		res = append(res, "out=none")
+	}
+
+	if len(t.Role) > 0 {
+		res = append(res, "role="+strings.Join(t.Role, "|"))
+	}
+
+	if t.Perm != "" {
+		res = append(res, "perm="+t.Perm)
+	}
+
+	if t.Idempotent {
+		res = append(res, "idempotent")
+	}
+
+	if t.Cache > 0 {
+		res = append(res, "cache="+t.Cache.String())
+	}
+
+	return res
+}
+
+func (t MethodPolicy) slow() time.Duration {
+	if t.Slow > 0 {
+		return t.Slow
+	}
+
+	return rpcQuerySlowThreshold
+}
+
+func (t MethodPolicy) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t.Timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, t.Timeout)
+}
+
+func (t MethodPolicy) allow(ctx context.Context) bool {
+	return len(t.Role) == 0 || HasRole(ctx, t.Role...)
+}
+
+func RoleRequiredRes(method string, role []string) *Res {
+	res := &Res{
+		Code: RspCodeForbidden,
+		Msg:  fmt.Sprintf(roleRequired, method, role),
+		Data: ByteOfNullJson,
+	}
+
+	return res
+}
+
+// applyPolicy 幂等、缓存与权限策略写入对应的全局注册表
+func (t *Lpc) applyPolicy(meta LpcMeta) {
+	p := meta.policy
+
+	if p.Idempotent {
+		MarkIdempotent(t.app, meta.name)
+	}
+
+	if p.Cache > 0 {
+		SetCacheRule(CacheRule{TTL: p.Cache, Tags: p.CacheTags}, t.app, meta.name)
+	}
+
+	if p.Perm != "" {
+		SetRbacPerm(p.Perm, t.app, meta.name)
+	}
+}
+
+func (t *Lpc) Policy(method string) (p MethodPolicy, ok bool) {
+	meta, ok := t.Meta(method)
+	if !ok {
+		return
+	}
+
+	return meta.policy, true
+}
+
+func (t *Lpc) Policies() map[string]string {
+	res := map[string]string{}
+
+	for _, v := range t.Method() {
+		p, ok := t.Policy(v)
+		if !ok || p.IsZero() {
+			continue
+		}
+
+		res[v] = strings.Join(p.Abstract(), ",")
+	}
+
+	return res
+}
+
+func policyLines(app string, m map[string]string) []string {
+	res := []string{}
+	for k, v := range m {
+		res = append(res, fmt.Sprintf(policyPat, app, k, v))
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+func outputLpc(ctx context.Context, level zapcore.Level, method string, err error, f ...zap.Field) {
+	if err != nil || level == zapcore.InfoLevel {
+		Logger(ctx).Output(method, err, f...)
+		return
+	}
+
+	ce := Logger(ctx).Check(level, method)
+	if ce != nil {
+		ce.Write(f...)
+	}
+}
@@ -0,0 +1,504 @@
+package transfer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	. "mykit/core/dsp"
+	. "mykit/core/persist"
+	. "mykit/core/types"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/jmoiron/sqlx"
+	"google.golang.org/grpc/metadata"
+)
+
+// 角色权限: 角色在app/method模式(path.Match语法)上授予read/write/access, 权限等级 read < write < access
+// 方法所需权限缺省为write, 可由MethodPolicy.Perm或SetRbacPerm声明
+// grpc入站的user/role须带各节点共享密钥的签名(TagSign), 未签名或密钥未配置时一律拒绝
+// 签名绑定目标app/method与签名时间(TagSignTs), 超过MdSignSkew或用于其他方法时失效
+
+const (
+	rpcUser      = "@#smarter#@"
+	rbacCacheKey = CacheKeyUserRole + "rbac"
+)
+
+var (
+	RbacTTL = time.Minute
+
+	// MdSignSkew md签名的有效期, 兼容节点间时钟偏差
+	MdSignSkew = time.Minute
+
+	// RBAC 为nil时不做权限校验
+	RBAC *Rbac
+
+	rbacRank = map[string]int{
+		PolicyRead:   1,
+		PolicyWrite:  2,
+		PolicyAccess: 3,
+	}
+
+	rbacLock sync.RWMutex
+	rbacPerm = map[string]string{}
+
+	mdSecret []byte
+)
+
+type mdTrustKey struct{}
+
+// SetMdSecret 网关与各服务须一致, 为空时不签名
+func SetMdSecret(secret string) {
+	mdSecret = []byte(secret)
+}
+
+func mdDigest(ts, trace, user, role, app, method string) string {
+	mac := hmac.New(sha256.New, mdSecret)
+	mac.Write([]byte(ts + "\n" + trace + "\n" + user + "\n" + role + "\n" + app + "\n" + method))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignMd 对trace/user/role及目标app/method签名, 须在trace/user/role写入md之后调用
+func SignMd(md map[string]string, app, method string) {
+	if len(mdSecret) == 0 {
+		return
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	md[TagSignTs] = ts
+	md[TagSign] = mdDigest(ts, md[TagTrace], md[TagUser], md[TagRole], app, method)
+}
+
+// signCtx 发出请求前按实际的req重新签名, 重试时时间戳随之更新
+func signCtx(ctx context.Context, req *Req) context.Context {
+	if len(mdSecret) == 0 {
+		return ctx
+	}
+
+	md, ok := MetaFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	sign := map[string]string{}
+	sign[TagTrace], _ = md.Get(TagTrace)
+	sign[TagUser], _ = md.Get(TagUser)
+	sign[TagRole], _ = md.Get(TagRole)
+
+	SignMd(sign, req.GetApp(), req.GetMethod())
+
+	return MergeMetaContext(ctx, map[string]string{
+		TagSign:   sign[TagSign],
+		TagSignTs: sign[TagSignTs],
+	})
+}
+
+func mdSigned(md metadata.MD, app, method string) bool {
+	if len(mdSecret) == 0 {
+		return false
+	}
+
+	first := func(k string) string {
+		v := md.Get(k)
+		if len(v) == 0 {
+			return ""
+		}
+
+		return v[0]
+	}
+
+	ts := first(TagSignTs)
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	d := time.Now().Sub(time.Unix(sec, 0))
+	if d > MdSignSkew || d < -MdSignSkew {
+		return false
+	}
+
+	sign := mdDigest(ts, first(TagTrace), first(TagUser), first(TagRole), app, method)
+
+	return hmac.Equal([]byte(first(TagSign)), []byte(sign))
+}
+
+// trustMd grpc入站的md须对本次请求的app/method签名, 在版本解析前以原始method校验
+func trustMd(ctx context.Context, app, method string) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, mdTrustKey{}, mdSigned(md, app, method))
+}
+
+type Permission struct {
+	Role   string `json:"role,omitempty" db:"role"`
+	App    string `json:"app" db:"app"`
+	Method string `json:"method" db:"method"`
+	Policy string `json:"policy" db:"policy"`
+}
+
+func (t Permission) Match(app, method string) bool {
+	ok, _ := path.Match(t.App, app)
+	if !ok {
+		return false
+	}
+
+	base, _ := ParseMethodVersion(method)
+	ok, _ = path.Match(t.Method, base)
+
+	return ok
+}
+
+type RbacTable struct {
+	Roles map[string][]Permission `json:"roles"`
+	Users map[string][]string     `json:"users"`
+}
+
+type RbacStore interface {
+	Load(ctx context.Context) (RbacTable, error)
+}
+
+// SetRbacPerm 声明方法所需权限, 未指定method时作用于整个app
+func SetRbacPerm(policy, app string, method ...string) {
+	rbacLock.Lock()
+	defer rbacLock.Unlock()
+
+	if len(method) == 0 {
+		rbacPerm[retryKey(app)] = policy
+		return
+	}
+
+	for _, v := range method {
+		rbacPerm[retryKey(app, v)] = policy
+	}
+}
+
+func GetRbacPerm(app, method string) string {
+	base, _ := ParseMethodVersion(method)
+
+	rbacLock.RLock()
+	defer rbacLock.RUnlock()
+
+	p, ok := rbacPerm[retryKey(app, base)]
+	if ok {
+		return p
+	}
+
+	p, ok = rbacPerm[retryKey(app)]
+	if ok {
+		return p
+	}
+
+	return PolicyWrite
+}
+
+type Rbac struct {
+	sync.RWMutex
+	store  RbacStore
+	cli    redis.Cmdable
+	table  RbacTable
+	loaded time.Time
+}
+
+func NewRbac(store RbacStore, cli redis.Cmdable) *Rbac {
+	res := &Rbac{
+		store: store,
+		cli:   cli,
+	}
+
+	return res
+}
+
+// Load 优先读redis缓存, 未命中时读store并回写
+func (t *Rbac) Load(ctx context.Context) error {
+	table, err := t.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.Lock()
+	t.table = table
+	t.loaded = time.Now()
+	t.Unlock()
+
+	return nil
+}
+
+func (t *Rbac) load(ctx context.Context) (table RbacTable, err error) {
+	if t.cli != nil {
+		raw, err := t.cli.Get(ctx, rbacCacheKey).Bytes()
+		if err == nil && json.Unmarshal(raw, &table) == nil {
+			return table, nil
+		}
+	}
+
+	table, err = t.store.Load(ctx)
+	if err != nil {
+		return
+	}
+
+	if t.cli != nil {
+		t.cli.Set(ctx, rbacCacheKey, ToJsonStr(table), RbacTTL)
+	}
+
+	return
+}
+
+// Invalidate 权限变更后调用, 清除redis缓存并重新加载
+func (t *Rbac) Invalidate(ctx context.Context) error {
+	if t.cli != nil {
+		err := t.cli.Del(ctx, rbacCacheKey).Err()
+		if err != nil {
+			return err
+		}
+	}
+
+	return t.Load(ctx)
+}
+
+func (t *Rbac) refresh(ctx context.Context) {
+	t.RLock()
+	stale := time.Now().Sub(t.loaded) > RbacTTL
+	t.RUnlock()
+
+	if !stale {
+		return
+	}
+
+	//加载失败时沿用旧数据
+	err := t.Load(ctx)
+	if err != nil {
+		LogS1.Warn(LogMsgGateway,
+			LogEvent("rbac"),
+			LogProcessor("load"),
+			LogError(err),
+		)
+	}
+}
+
+func (t *Rbac) Roles(ctx context.Context, user string, role ...string) []string {
+	t.refresh(ctx)
+
+	t.RLock()
+	defer t.RUnlock()
+
+	return append(append([]string{}, role...), t.table.Users[user]...)
+}
+
+// Can 用户自身角色与附加角色(如context中的role)任一满足即可
+func (t *Rbac) Can(ctx context.Context, user, app, method string, role ...string) bool {
+	roles := t.Roles(ctx, user, role...)
+	need := rbacRank[GetRbacPerm(app, method)]
+
+	t.RLock()
+	defer t.RUnlock()
+
+	for _, v := range roles {
+		for _, v2 := range t.table.Roles[v] {
+			if rbacRank[v2.Policy] >= need && v2.Match(app, method) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func ForbiddenRes(app, method string) *Res {
+	res := &Res{
+		Code: RspCodeForbidden,
+		Msg:  fmt.Sprintf(rbacDenied, app, method),
+		Data: ByteOfNullJson,
+	}
+
+	return res
+}
+
+// rbacCheck 框架内部rpc不做校验, 经grpc入站时须签名有效
+func rbacCheck(ctx context.Context, app, method string) *Res {
+	if RBAC == nil {
+		return nil
+	}
+
+	trusted, ok := ctx.Value(mdTrustKey{}).(bool)
+	if ok && !trusted {
+		return ForbiddenRes(app, method)
+	}
+
+	user := GetUser(ctx)
+	if user == rpcUser {
+		return nil
+	}
+
+	if RBAC.Can(ctx, user, app, method, GetRole(ctx)...) {
+		return nil
+	}
+
+	return ForbiddenRes(app, method)
+}
+
+func (t *LpcDispatch) Rbac(r *Rbac) {
+	RBAC = r
+}
+
+// ginRbacCheck user/role取自鉴权中间件写入的gin context
+func ginRbacCheck(c *gin.Context, app, method string) *Res {
+	return rbacCan(c, GetUserAccount(c), GetRole(c), app, method)
+}
+
+func rbacCan(ctx context.Context, user string, role []string, app, method string) *Res {
+	if RBAC == nil || RBAC.Can(ctx, user, app, method, role...) {
+		return nil
+	}
+
+	return ForbiddenRes(app, method)
+}
+
+// RbacMiddleware 用于/:app/:method路由
+func (t SmarterRouter) RbacMiddleware() gin.HandlerFunc {
+	var h = func(c *gin.Context) {
+		if RBAC == nil {
+			c.Next()
+			return
+		}
+
+		deny := ginRbacCheck(c, c.Param(TagApp), c.Param(TagMethod))
+		if deny != nil {
+			NewFinalRsp2(deny.Msg, deny.Code).Send(c)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+
+	return h
+}
+
+type RbacQuery struct {
+	User   string   `json:"user"`
+	Role   []string `json:"role,optional"`
+	App    string   `json:"app"`
+	Method string   `json:"method"`
+}
+
+type RbacAnswer struct {
+	Allow  bool     `json:"allow"`
+	Policy string   `json:"policy"`
+	Roles  []string `json:"roles"`
+}
+
+func (t *Rbac) Query(ctx context.Context, q RbacQuery) RbacAnswer {
+	res := RbacAnswer{
+		Allow:  t.Can(ctx, q.User, q.App, q.Method, q.Role...),
+		Policy: GetRbacPerm(q.App, q.Method),
+		Roles:  t.Roles(ctx, q.User, q.Role...),
+	}
+
+	return res
+}
+
+// CanHandler 查询当前用户能否调用app/method, 请求中的user/role被忽略
+func (t SmarterRouter) CanHandler() func(c *gin.Context) {
+	var h = func(c *gin.Context) {
+		var q RbacQuery
+		err := c.ShouldBindJSON(&q)
+		if err != nil || RBAC == nil {
+			SendRsp2(c, nil, BadRequestRes(CodeInvalidArgument), nil)
+			return
+		}
+
+		q.User = GetUserAccount(c)
+		q.Role = GetRole(c)
+
+		SuccessFinalRsp2(RBAC.Query(c, q)).Send(c)
+	}
+
+	return h
+}
+
+// SqlRbacStore 表结构: perm(role, app, method, policy), user_role(user, role)
+type SqlRbacStore struct {
+	Db        *sqlx.DB
+	PermTable string
+	UserTable string
+}
+
+func (t SqlRbacStore) Load(ctx context.Context) (res RbacTable, err error) {
+	perm := []Permission{}
+	err = t.Db.SelectContext(ctx, &perm, "SELECT role, app, method, policy FROM "+t.PermTable)
+	if err != nil {
+		return
+	}
+
+	user := []struct {
+		User string `db:"user"`
+		Role string `db:"role"`
+	}{}
+	err = t.Db.SelectContext(ctx, &user, "SELECT `user`, role FROM "+t.UserTable)
+	if err != nil {
+		return
+	}
+
+	res = RbacTable{
+		Roles: map[string][]Permission{},
+		Users: map[string][]string{},
+	}
+
+	for _, v := range perm {
+		res.Roles[v.Role] = append(res.Roles[v.Role], v)
+	}
+
+	for _, v := range user {
+		res.Users[v.User] = append(res.Users[v.User], v.Role)
+	}
+
+	return
+}
+
+// EtcdRbacStore key的值为RbacTable的json
+type EtcdRbacStore struct {
+	Etcd *EtcdContext
+	Key  string
+}
+
+func (t EtcdRbacStore) Load(ctx context.Context) (res RbacTable, err error) {
+	err = t.Etcd.Get(ctx, t.Key, &res)
+
+	return
+}
+
+// Watch key变更时刷新r
+func (t EtcdRbacStore) Watch(r *Rbac) {
+	go t.Etcd.Watch(t.Key, func(kv *mvccpb.KeyValue) {
+		if string(kv.Key) != t.Key {
+			return
+		}
+
+		err := r.Invalidate(Ctx)
+		if err != nil {
+			LogS1.Warn(LogMsgSetup,
+				LogEvent("rbac"),
+				LogProcessor(t.Key),
+				LogError(err),
+			)
+		}
+	})
+}
+
+func PutRbacTable(ctx context.Context, etcd *EtcdContext, key string, table RbacTable) error {
+	return etcd.Put(ctx, key, table)
+}
@@ -0,0 +1,252 @@
+package transfer
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	. "mykit/core/dsp"
+	. "mykit/core/persist"
+	. "mykit/core/types"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/mvcc/mvccpb"
+)
+
+type RetryPolicy struct {
+	MaxAttempts int
+	Base        time.Duration
+	Max         time.Duration
+	Multiplier  float64
+	Jitter      float64      //0~1, 按比例随机抖动
+	Codes       []int32      //可重试的Res.Code, 传输错误总是可重试
+	Interval    TaskInterval //设置后替代指数退避
+}
+
+var (
+	DefaultRetryPolicy = RetryPolicy{
+		MaxAttempts: int(rpcRetryTimes),
+		Base:        time.Millisecond * 100,
+		Max:         time.Second * 2,
+		Multiplier:  2,
+		Jitter:      0.2,
+		Codes: []int32{
+			CodeUnavailable,
+			CodeDeadlineExceeded,
+			http.StatusInternalServerError,
+		},
+	}
+)
+
+func (t RetryPolicy) Backoff(attempt int) time.Duration {
+	d := float64(t.Base) * math.Pow(math.Max(t.Multiplier, 1), float64(attempt))
+	if t.Max > 0 && d > float64(t.Max) {
+		d = float64(t.Max)
+	}
+
+	if t.Jitter > 0 {
+		d += d * t.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	return time.Duration(d)
+}
+
+func (t RetryPolicy) Retryable(rsp *Res, err error) bool {
+	if err != nil || rsp == nil {
+		return true
+	}
+
+	code := rsp.GetCode()
+	for _, v := range t.Codes {
+		if v == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Do 重试至成功、不可重试或ctx结束, 返回最后一次结果
+func (t RetryPolicy) Do(ctx context.Context, call func(ctx context.Context) (*Res, error)) (rsp *Res, err error) {
+	n := t.MaxAttempts
+	if n < 1 {
+		n = 1
+	}
+
+	for i := 0; i < n; i++ {
+		rsp, err = call(ctx)
+		if i == n-1 || !t.Retryable(rsp, err) {
+			return
+		}
+
+		d := t.Backoff(i)
+		if t.Interval != nil {
+			d = t.Interval()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d):
+		}
+	}
+
+	return
+}
+
+var (
+	retryLock   sync.RWMutex
+	retryPolicy = map[string]RetryPolicy{}
+	idempotent  = map[string]bool{}
+
+	// idemRemote 各服务经etcd发布的幂等标记, etcd key -> 标记
+	idemRemote = map[string]map[string]bool{}
+)
+
+func retryKey(app string, method ...string) string {
+	if len(method) == 0 || method[0] == "" {
+		return app
+	}
+
+	return app + "." + method[0]
+}
+
+// SetRetryPolicy 未指定app时修改默认策略, 未指定method时作用于整个app
+func SetRetryPolicy(p RetryPolicy, app string, method ...string) {
+	retryLock.Lock()
+	defer retryLock.Unlock()
+
+	if app == "" {
+		DefaultRetryPolicy = p
+		return
+	}
+
+	if len(method) == 0 {
+		retryPolicy[retryKey(app)] = p
+		return
+	}
+
+	for _, v := range method {
+		retryPolicy[retryKey(app, v)] = p
+	}
+}
+
+func GetRetryPolicy(app, method string) RetryPolicy {
+	retryLock.RLock()
+	defer retryLock.RUnlock()
+
+	p, ok := retryPolicy[retryKey(app, method)]
+	if ok {
+		return p
+	}
+
+	p, ok = retryPolicy[retryKey(app)]
+	if ok {
+		return p
+	}
+
+	return DefaultRetryPolicy
+}
+
+// MarkIdempotent 只有幂等方法会被自动重试, 未指定method时整个app视为幂等
+// 重试由调用方决定, 服务端的标记须经PutIdempotentMarks发布, 调用方WatchIdempotentMarks后生效
+func MarkIdempotent(app string, method ...string) {
+	retryLock.Lock()
+	defer retryLock.Unlock()
+
+	if len(method) == 0 {
+		idempotent[retryKey(app)] = true
+		return
+	}
+
+	for _, v := range method {
+		idempotent[retryKey(app, v)] = true
+	}
+}
+
+func IsIdempotent(app, method string) bool {
+	retryLock.RLock()
+	defer retryLock.RUnlock()
+
+	k1 := retryKey(app, method)
+	k2 := retryKey(app)
+
+	if idempotent[k1] || idempotent[k2] {
+		return true
+	}
+
+	for _, v := range idemRemote {
+		if v[k1] || v[k2] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Idempotents 本进程标记的幂等app/方法
+func Idempotents() []string {
+	retryLock.RLock()
+	res := []string{}
+	for k := range idempotent {
+		res = append(res, k)
+	}
+	retryLock.RUnlock()
+
+	sort.Strings(res)
+
+	return res
+}
+
+func PutIdempotentMarks(ctx context.Context, etcd *EtcdContext, key string) error {
+	return etcd.Put(ctx, key, Idempotents())
+}
+
+func applyIdempotentMarks(key string, raw []byte) {
+	marks := []string{}
+	err := UnmarshalJson(raw, &marks)
+	if err != nil {
+		LogS1.Warn(LogMsgSetup,
+			LogEvent("idempotent"),
+			LogProcessor(key),
+			LogError(err),
+		)
+		return
+	}
+
+	m := map[string]bool{}
+	for _, v := range marks {
+		m[v] = true
+	}
+
+	retryLock.Lock()
+	idemRemote[key] = m
+	retryLock.Unlock()
+}
+
+// WatchIdempotentMarks 加载prefix下各服务发布的幂等标记, 并监听后续变更
+func WatchIdempotentMarks(etcd *EtcdContext, prefix string) {
+	for k, v := range etcd.GetWithPrefix(Ctx, prefix) {
+		applyIdempotentMarks(k, StringToBytes(v))
+	}
+
+	go etcd.Watch(prefix, func(kv *mvccpb.KeyValue) {
+		applyIdempotentMarks(string(kv.Key), kv.Value)
+	})
+}
+
+// RetryFor 非幂等方法只调用一次
+func RetryFor(app, method string) RetryPolicy {
+	p := GetRetryPolicy(app, method)
+	if !IsIdempotent(app, method) {
+		p.MaxAttempts = 1
+	}
+
+	return p
+}
+
+func (t *LpcDispatch) Idempotent(app string, method ...string) {
+	MarkIdempotent(app, method...)
+}
@@ -0,0 +1,247 @@
+package transfer
+
+import (
+	"context"
+	"io"
+	. "mykit/core/dsp"
+	. "mykit/core/persist"
+	. "mykit/core/types"
+	"sort"
+	"strings"
+
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"github.com/gin-gonic/gin"
+)
+
+// RouteTable service -> app列表, 与RegAppDispatch的参数一致
+// service未带rpc前缀时自动补全
+type RouteTable map[string][]string
+
+var (
+	// routeApplied 上次由路由表写入的app -> service
+	routeApplied = map[string]string{}
+	// routeStatic 首次应用路由表前的静态映射, app移出路由表后恢复
+	routeStatic map[string]string
+	// routeOwned 由路由表创建client的service, 只有这些会被移除
+	routeOwned = map[string]bool{}
+)
+
+func routeService(raw string) string {
+	if strings.HasPrefix(raw, rpcPrefix) {
+		return raw
+	}
+
+	return RpcEndpoint(raw)
+}
+
+func (t RouteTable) Apps() map[string]string {
+	res := map[string]string{}
+	for k, v := range t {
+		service := routeService(k)
+		for _, v2 := range v {
+			res[v2] = service
+		}
+	}
+
+	return res
+}
+
+// DispatchServices AppDispatch中出现的全部service
+func DispatchServices() []string {
+	m := map[string]bool{}
+	for _, v := range GetAppDispatch() {
+		m[v] = true
+	}
+
+	res := []string{}
+	for k := range m {
+		res = append(res, k)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// ApplyRoutes 新service即时注册client, 从路由表移除的app不再转发(有静态映射时恢复)
+// 由路由表创建且不再被任何app引用的service同时移除client, 静态注册的service保留
+func (t SmarterRouter) ApplyRoutes(etcd EtcdConfig, table RouteTable) {
+	next := table.Apps()
+
+	//client创建较慢, 在锁外完成
+	created := []string{}
+	failed := map[string]bool{}
+	for _, v := range next {
+		_, ok := t.Get(v)
+		if ok || failed[v] {
+			continue
+		}
+
+		c, err := sharedClient(v, etcd)
+		if err != nil {
+			LogS1.Warn(LogMsgSetup,
+				LogEvent("route"),
+				LogProcessor(v),
+				LogError(err),
+			)
+
+			failed[v] = true
+			continue
+		}
+
+		t.AddService(v, NewSmarterService(v, c))
+		created = append(created, v)
+	}
+
+	add := []string{}
+	remove := []string{}
+	closed := []SmarterService{}
+
+	routeLock.Lock()
+
+	for _, v := range created {
+		routeOwned[v] = true
+	}
+
+	//client创建失败的service跳过, 其app沿用上次的路由
+	for k, v := range next {
+		if !failed[v] {
+			continue
+		}
+
+		old, ok := routeApplied[k]
+		if ok {
+			next[k] = old
+		} else {
+			delete(next, k)
+		}
+	}
+
+	if routeStatic == nil {
+		routeStatic = map[string]string{}
+		for k, v := range AppDispatch {
+			routeStatic[k] = v
+		}
+	}
+
+	for k, v := range routeApplied {
+		_, ok := next[k]
+		if ok || AppDispatch[k] != v {
+			continue
+		}
+
+		s, static := routeStatic[k]
+		if static {
+			AppDispatch[k] = s
+		} else {
+			delete(AppDispatch, k)
+		}
+
+		remove = append(remove, k)
+	}
+
+	for k, v := range next {
+		if AppDispatch[k] != v {
+			add = append(add, k+" -> "+v)
+		}
+
+		AppDispatch[k] = v
+	}
+
+	used := map[string]bool{}
+	for _, v := range AppDispatch {
+		used[v] = true
+	}
+
+	for k := range routeOwned {
+		if !used[k] {
+			closed = append(closed, t[k])
+			delete(t, k)
+			delete(routeOwned, k)
+		}
+	}
+
+	routeApplied = next
+
+	routeLock.Unlock()
+
+	//grpc client共享连接无需释放, 自定义的SmarterService(如录制)可实现io.Closer
+	for _, v := range closed {
+		c, ok := v.(io.Closer)
+		if ok {
+			c.Close()
+		}
+	}
+
+	if len(add)+len(remove) == 0 {
+		return
+	}
+
+	sort.Strings(add)
+	sort.Strings(remove)
+
+	LogS1.Info(LogMsgSetup,
+		LogEvent("route"),
+		LogDetail(map[string]interface{}{
+			"add":    add,
+			"remove": remove,
+		}),
+	)
+}
+
+// WatchRoutes 先加载key的当前值, 再监听后续变更
+func (t SmarterRouter) WatchRoutes(etcd *EtcdContext, conf EtcdConfig, key string) {
+	table := RouteTable{}
+	err := etcd.Get(Ctx, key, &table)
+	if err != nil {
+		//沿用静态映射, 等待后续变更
+		LogS1.Warn(LogMsgSetup,
+			LogEvent("route"),
+			LogProcessor(key),
+			LogError(err),
+		)
+	} else {
+		t.ApplyRoutes(conf, table)
+	}
+
+	go etcd.Watch(key, func(kv *mvccpb.KeyValue) {
+		if string(kv.Key) != key {
+			return
+		}
+
+		table := RouteTable{}
+		err := UnmarshalJson(kv.Value, &table)
+		if err != nil {
+			LogS1.Warn(LogMsgSetup,
+				LogEvent("route"),
+				LogProcessor(key),
+				LogError(err),
+			)
+			return
+		}
+
+		t.ApplyRoutes(conf, table)
+	})
+}
+
+func PutRouteTable(ctx context.Context, etcd *EtcdContext, key string, table RouteTable) error {
+	return etcd.Put(ctx, key, table)
+}
+
+type RouteStat struct {
+	Dispatch map[string]string `json:"dispatch"`
+	Services []string          `json:"services"`
+}
+
+func (t SmarterRouter) RouteHandler() func(c *gin.Context) {
+	var h = func(c *gin.Context) {
+		res := RouteStat{
+			Dispatch: GetAppDispatch(),
+			Services: t.Services(),
+		}
+
+		SuccessFinalRsp2(res).Send(c)
+	}
+
+	return h
+}
@@ -0,0 +1,284 @@
+package transfer
+
+import (
+	"encoding/json"
+	. "mykit/core/persist"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+	tagValidate     = "validate"
+)
+
+type JsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Properties           map[string]*JsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JsonSchema            `json:"items,omitempty"`
+	AdditionalProperties *JsonSchema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *uint64                `json:"minLength,omitempty"`
+	MaxLength            *uint64                `json:"maxLength,omitempty"`
+	MinItems             *uint64                `json:"minItems,omitempty"`
+	MaxItems             *uint64                `json:"maxItems,omitempty"`
+	Validate             string                 `json:"x-validate,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	bytesType      = reflect.TypeOf([]byte{})
+)
+
+func NewJsonSchema(t reflect.Type, title ...string) *JsonSchema {
+	res := schemaOf(t, map[reflect.Type]bool{})
+	res.Schema = jsonSchemaDraft
+
+	if len(title) > 0 {
+		res.Title = title[0]
+	}
+
+	return res
+}
+
+func schemaOf(t reflect.Type, visited map[reflect.Type]bool) *JsonSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &JsonSchema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &JsonSchema{}
+	case bytesType:
+		return &JsonSchema{Type: "string", Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JsonSchema{Type: "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JsonSchema{Type: "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return &JsonSchema{Type: "number"}
+
+	case reflect.String:
+		return &JsonSchema{Type: "string"}
+
+	case reflect.Slice, reflect.Array:
+		return &JsonSchema{Type: "array", Items: schemaOf(t.Elem(), visited)}
+
+	case reflect.Map:
+		return &JsonSchema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), visited)}
+
+	case reflect.Struct:
+		if visited[t] {
+			return &JsonSchema{Type: "object", Title: t.Name()}
+		}
+
+		visited[t] = true
+		defer delete(visited, t)
+
+		res := &JsonSchema{
+			Type:       "object",
+			Properties: map[string]*JsonSchema{},
+		}
+		structSchema(t, res, visited)
+
+		return res
+	}
+
+	return &JsonSchema{}
+}
+
+func structSchema(t reflect.Type, res *JsonSchema, visited map[reflect.Type]bool) {
+	n := t.NumField()
+	for i := 0; i < n; i++ {
+		field := t.Field(i)
+
+		name, opt, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if field.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			structSchema(ft, res, visited)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		item := schemaOf(field.Type, visited)
+		if applyValidate(item, field.Tag.Get(tagValidate)) && !opt {
+			res.Required = append(res.Required, name)
+		}
+
+		res.Properties[name] = item
+	}
+}
+
+func jsonFieldName(field reflect.StructField) (name string, optional, skip bool) {
+	tag := field.Tag.Get(TagJson)
+	if tag == "-" {
+		return "", false, true
+	}
+
+	tmp := strings.Split(tag, ",")
+	name = tmp[0]
+
+	for _, v := range tmp[1:] {
+		if v == "omitempty" || v == "optional" || strings.HasPrefix(v, "default=") {
+			optional = true
+		}
+	}
+
+	return
+}
+
+// applyValidate 将validate约束映射到schema, 返回是否必填
+func applyValidate(s *JsonSchema, tag string) (required bool) {
+	if tag == "" {
+		return
+	}
+
+	s.Validate = tag
+
+	for _, v := range strings.Split(tag, ",") {
+		k, arg := v, ""
+		if p := strings.Index(v, "="); p > 0 {
+			k, arg = v[:p], v[p+1:]
+		}
+
+		switch k {
+		case "required":
+			required = true
+
+		case "min", "gte":
+			setLowerBound(s, arg, false)
+
+		case "max", "lte":
+			setUpperBound(s, arg, false)
+
+		case "gt":
+			setLowerBound(s, arg, true)
+
+		case "lt":
+			setUpperBound(s, arg, true)
+
+		case "len":
+			setLowerBound(s, arg, false)
+			setUpperBound(s, arg, false)
+
+		case "oneof":
+			for _, e := range strings.Fields(arg) {
+				s.Enum = append(s.Enum, enumValue(s.Type, e))
+			}
+
+		case "email":
+			s.Format = "email"
+
+		case "url", "uri":
+			s.Format = "uri"
+
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+
+		case "ipv4", "ip4_addr":
+			s.Format = "ipv4"
+
+		case "ipv6", "ip6_addr":
+			s.Format = "ipv6"
+		}
+	}
+
+	return
+}
+
+func setLowerBound(s *JsonSchema, arg string, exclusive bool) {
+	switch s.Type {
+	case "string":
+		if n, err := strconv.ParseUint(arg, 10, 64); err == nil {
+			s.MinLength = &n
+		}
+
+	case "array":
+		if n, err := strconv.ParseUint(arg, 10, 64); err == nil {
+			s.MinItems = &n
+		}
+
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(arg, 64); err == nil {
+			if exclusive {
+				s.ExclusiveMinimum = &f
+			} else {
+				s.Minimum = &f
+			}
+		}
+	}
+}
+
+func setUpperBound(s *JsonSchema, arg string, exclusive bool) {
+	switch s.Type {
+	case "string":
+		if n, err := strconv.ParseUint(arg, 10, 64); err == nil {
+			s.MaxLength = &n
+		}
+
+	case "array":
+		if n, err := strconv.ParseUint(arg, 10, 64); err == nil {
+			s.MaxItems = &n
+		}
+
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(arg, 64); err == nil {
+			if exclusive {
+				s.ExclusiveMaximum = &f
+			} else {
+				s.Maximum = &f
+			}
+		}
+	}
+}
+
+func enumValue(kind, raw string) interface{} {
+	switch kind {
+	case "integer":
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return n
+		}
+
+	case "number":
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			return f
+		}
+	}
+
+	return raw
+}
@@ -0,0 +1,213 @@
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	. "mykit/core/dsp"
+	. "mykit/core/persist"
+	. "mykit/core/types"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 影子流量: 按比例将请求异步复制到Target服务, 其响应不返回给客户端, 仅与主响应比较并记录差异
+// 只复制幂等方法, 影子请求不带Idempotency-Key且不读写方法缓存
+
+type ShadowConfig struct {
+	Target  string  //SmarterRouter中的服务名
+	Rate    float64 //复制比例 0~1
+	Timeout time.Duration
+	LogSame bool //一致时也记录
+	Write   bool //也复制非幂等方法, 影子服务须按IsShadow自行隔离副作用
+}
+
+const (
+	TagShadow = "shadow"
+)
+
+var (
+	ShadowConcurrency = 64
+
+	shadowLock    sync.RWMutex
+	shadowConf    = map[string]ShadowConfig{}
+	shadowRunning int32
+)
+
+func SetShadow(conf ShadowConfig, app ...string) {
+	if conf.Timeout <= 0 {
+		conf.Timeout = time.Second * 5
+	}
+
+	shadowLock.Lock()
+	for _, v := range app {
+		shadowConf[v] = conf
+	}
+	shadowLock.Unlock()
+}
+
+func RemoveShadow(app ...string) {
+	shadowLock.Lock()
+	for _, v := range app {
+		delete(shadowConf, v)
+	}
+	shadowLock.Unlock()
+}
+
+func GetShadow(app string) (conf ShadowConfig, ok bool) {
+	shadowLock.RLock()
+	conf, ok = shadowConf[app]
+	shadowLock.RUnlock()
+
+	return
+}
+
+// IsShadow 影子服务可据此跳过外部副作用
+func IsShadow(ctx context.Context) bool {
+	return GetStringFromContext(ctx, TagShadow) != ""
+}
+
+// Shadow 注册影子服务客户端并为app开启流量复制
+func (t SmarterRouter) Shadow(etcd EtcdConfig, conf ShadowConfig, app ...string) {
+	_, ok := t.Get(conf.Target)
+	if !ok {
+		t.Add(etcd, conf.Target)
+	}
+
+	SetShadow(conf, app...)
+}
+
+func (t SmarterRouter) sampleShadow(req *Req) (shadow *Req, conf ShadowConfig, ok bool) {
+	conf, ok = GetShadow(req.GetApp())
+	if !ok || rand.Float64() >= conf.Rate {
+		return nil, conf, false
+	}
+
+	if !conf.Write && !IsIdempotent(req.GetApp(), req.GetMethod()) {
+		return nil, conf, false
+	}
+
+	_, ok = t.Get(conf.Target)
+	if !ok {
+		return nil, conf, false
+	}
+
+	shadow = &Req{
+		App:    req.GetApp(),
+		Method: req.GetMethod(),
+		Param:  append([]byte{}, req.GetParam()...),
+	}
+
+	return
+}
+
+// shadowCall 影子请求使用转码前的json参数, 与转码后的主响应比较
+func (t SmarterRouter) shadowCall(ctx context.Context, codec string, req *Req, call Caller) (*Res, error) {
+	shadow, conf, ok := t.sampleShadow(req)
+
+	rsp, err := transcodeCall(ctx, codec, req, call)
+	if !ok || err != nil || rsp == nil {
+		return rsp, err
+	}
+
+	//影子流量不能拖垮网关, 满载时直接丢弃
+	if atomic.AddInt32(&shadowRunning, 1) > int32(ShadowConcurrency) {
+		atomic.AddInt32(&shadowRunning, -1)
+		return rsp, err
+	}
+
+	md, _ := MetaFromContext(ctx)
+	primary := &Res{Code: rsp.GetCode(), Msg: rsp.GetMsg(), Data: append([]byte{}, rsp.GetData()...)}
+
+	go func() {
+		defer atomic.AddInt32(&shadowRunning, -1)
+
+		t.mirror(md, conf, shadow, primary)
+	}()
+
+	return rsp, err
+}
+
+func (t SmarterRouter) mirror(md map[string]string, conf ShadowConfig, req *Req, primary *Res) {
+	defer Recover("shadow")
+
+	meta := map[string]string{}
+	for k, v := range md {
+		meta[k] = v
+	}
+	meta[TagShadow] = conf.Target
+
+	//与主请求共用key会命中或占用主请求的幂等记录
+	delete(meta, TagIdempotency)
+
+	ctx, cancel := context.WithTimeout(NewMetaContext(Ctx, meta), conf.Timeout)
+	defer cancel()
+
+	t0 := time.Now()
+	client, ok := t.Get(conf.Target)
+	if !ok {
+		return
+	}
+
+	rsp, err := client.Call(WithTimeoutMeta(signCtx(ctx, req)), req)
+	cost := time.Now().Sub(t0)
+
+	method := req.GetApp() + "." + req.GetMethod()
+
+	if err != nil {
+		ZapFailed(LogS1,
+			LogEvent("shadow"),
+			LogProcessor(method),
+			LogDuration(cost),
+			LogError(err),
+		)
+		return
+	}
+
+	same := SameRes(primary, rsp)
+	if same && !conf.LogSame {
+		return
+	}
+
+	detail := map[string]interface{}{
+		"same":    same,
+		"target":  conf.Target,
+		"primary": shadowDetail(primary),
+		"shadow":  shadowDetail(rsp),
+	}
+
+	LogS1.Warn(LogMsgGateway,
+		LogEvent("shadow diff"),
+		LogProcessor(method),
+		LogDuration(cost),
+		LogDetail(detail),
+	)
+}
+
+func shadowDetail(rsp *Res) FinalRsp2 {
+	res := FinalRsp2{
+		Code: rsp.GetCode(),
+		Msg:  rsp.GetMsg(),
+		Data: EnsureJsonByte(rsp.GetData()),
+	}
+
+	return res
+}
+
+// SameRes 比较code与json语义相同的data, 忽略字段顺序与空白
+func SameRes(a, b *Res) bool {
+	if a.GetCode() != b.GetCode() {
+		return false
+	}
+
+	var x, y interface{}
+	errX := json.Unmarshal(EnsureJsonByte(a.GetData()), &x)
+	errY := json.Unmarshal(EnsureJsonByte(b.GetData()), &y)
+	if errX != nil || errY != nil {
+		return string(a.GetData()) == string(b.GetData())
+	}
+
+	return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,305 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	. "mykit/core/dsp"
+	. "mykit/core/persist"
+	. "mykit/core/types"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// 请求签名: 待签串为 method\npath\nts\nnonce\nhex(sha256(body)), path含query
+// HMAC客户端 Sign = hex(hmac-sha256(secret, 待签串))
+// RSA客户端 Sign = base64(rsa-pkcs1v15-sha256(privateKey, 待签串))
+
+const (
+	signNoncePrefix = "lpc:nonce:"
+	TagSignClient   = "signClient"
+	HeadClientId    = "X-Client-Id" //签名客户端标识, 与表示客户端类型的Client头无关
+)
+
+var (
+	SignSkew = time.Minute * 5
+
+	// SignMaxBody 参与签名的请求体上限, 超出时返回413
+	SignMaxBody int64 = 1 << 20
+)
+
+type SignClient struct {
+	Secret    string `json:"secret"`    //HMAC密钥
+	PublicPem string `json:"publicPem"` //RSA公钥, 设置后只接受RSA签名
+	Disabled  bool   `json:"disabled"`
+	publicKey *rsa.PublicKey
+}
+
+func (t *SignClient) parse() error {
+	if t.PublicPem == "" {
+		if t.Secret == "" {
+			return ErrInvalidParam
+		}
+
+		return nil
+	}
+
+	block, _ := pem.Decode(StringToBytes(t.PublicPem))
+	if block == nil {
+		return ErrInvalidParam
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return ErrInvalidParam
+	}
+
+	t.publicKey = pub
+
+	return nil
+}
+
+func (t *SignClient) verify(payload, sign string) bool {
+	if t.publicKey == nil {
+		expect := HmacSign(t.Secret, payload)
+		return subtle.ConstantTimeCompare(StringToBytes(expect), StringToBytes(sign)) == 1
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return false
+	}
+
+	h := sha256.Sum256(StringToBytes(payload))
+
+	return rsa.VerifyPKCS1v15(t.publicKey, crypto.SHA256, h[:], raw) == nil
+}
+
+func SignPayload(method, path, ts, nonce string, body []byte) string {
+	h := sha256.Sum256(body)
+
+	return strings.Join([]string{method, path, ts, nonce, hex.EncodeToString(h[:])}, "\n")
+}
+
+func HmacSign(secret, payload string) string {
+	m := hmac.New(sha256.New, StringToBytes(secret))
+	m.Write(StringToBytes(payload))
+
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+func RsaSign(key *rsa.PrivateKey, payload string) (string, error) {
+	h := sha256.Sum256(StringToBytes(payload))
+
+	raw, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// SignRequest 供调用方设置签名相关header, sign为签名函数(HmacSign或RsaSign的闭包)
+func SignRequest(req *http.Request, client string, body []byte, sign func(payload string) (string, error)) error {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := randNonce()
+
+	s, err := sign(SignPayload(req.Method, req.URL.RequestURI(), ts, nonce, body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(HeadClientId, client)
+	req.Header.Set(HeadTs, ts)
+	req.Header.Set(HeadNonce, nonce)
+	req.Header.Set(HeadSign, s)
+
+	return nil
+}
+
+func randNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+type SignVerifier struct {
+	sync.RWMutex
+	clients map[string]*SignClient
+	cli     redis.Cmdable
+	Skew    time.Duration
+}
+
+func NewSignVerifier(cli redis.Cmdable) *SignVerifier {
+	res := &SignVerifier{
+		clients: map[string]*SignClient{},
+		cli:     cli,
+		Skew:    SignSkew,
+	}
+
+	return res
+}
+
+func (t *SignVerifier) SetClient(id string, client SignClient) error {
+	err := client.parse()
+	if err != nil {
+		return err
+	}
+
+	t.Lock()
+	t.clients[id] = &client
+	t.Unlock()
+
+	return nil
+}
+
+func (t *SignVerifier) RemoveClient(id ...string) {
+	t.Lock()
+	for _, v := range id {
+		delete(t.clients, v)
+	}
+	t.Unlock()
+}
+
+func (t *SignVerifier) Client(id string) (*SignClient, bool) {
+	t.RLock()
+	res, ok := t.clients[id]
+	t.RUnlock()
+
+	return res, ok && !res.Disabled
+}
+
+func (t *SignVerifier) putClient(key, id string, raw []byte) {
+	var client SignClient
+	err := UnmarshalJson(raw, &client)
+	if err == nil {
+		err = t.SetClient(id, client)
+	}
+
+	if err != nil {
+		LogS1.Warn(LogMsgSetup,
+			LogEvent("sign"),
+			LogProcessor(key),
+			LogError(err),
+		)
+	}
+}
+
+// WatchClients 客户端配置存于etcd prefix/<client>, 值为SignClient的json
+// 先加载当前值再监听变更, 吊销客户端时置Disabled
+func (t *SignVerifier) WatchClients(etcd *EtcdContext, prefix string) {
+	PadSuffix(&prefix, EtcdDelimiter)
+
+	for k, v := range etcd.GetWithPrefix(Ctx, prefix) {
+		t.putClient(k, strings.TrimPrefix(k, prefix), StringToBytes(v))
+	}
+
+	go etcd.Watch(prefix, func(kv *mvccpb.KeyValue) {
+		k := BytesToString(kv.Key)
+		t.putClient(k, strings.TrimPrefix(k, prefix), kv.Value)
+	})
+}
+
+func (t *SignVerifier) Verify(ctx context.Context, id, method, path, ts, nonce, sign string, body []byte) error {
+	client, ok := t.Client(id)
+	if !ok {
+		return ErrSignClient
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || nonce == "" || sign == "" {
+		return ErrSignInvalid
+	}
+
+	d := time.Now().Sub(time.Unix(sec, 0))
+	if d > t.Skew || d < -t.Skew {
+		return ErrSignStale
+	}
+
+	if !client.verify(SignPayload(method, path, ts, nonce, body), sign) {
+		return ErrSignInvalid
+	}
+
+	//签名通过后再占用nonce, 防止伪造请求耗尽nonce; 过期时间覆盖整个允许偏差
+	if t.cli != nil {
+		ok, err = t.cli.SetNX(ctx, signNoncePrefix+id+":"+nonce, 1, t.Skew*2).Result()
+		if err != nil {
+			//无法校验重放时拒绝请求
+			Logger(ctx).Error(LogMsgGateway, LogEvent("sign"), LogProcessor(id), LogError(err))
+			return ErrFailed
+		}
+
+		if !ok {
+			return ErrSignReplay
+		}
+	}
+
+	return nil
+}
+
+func (t *SignVerifier) Middleware() gin.HandlerFunc {
+	var h = func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, SignMaxBody))
+		if err != nil {
+			code := http.StatusBadRequest
+
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				code = http.StatusRequestEntityTooLarge
+			}
+
+			c.JSON(code, NewFinalRsp(err.Error(), int32(code)))
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		id := c.GetHeader(HeadClientId)
+		err = t.Verify(c, id,
+			c.Request.Method, c.Request.URL.RequestURI(),
+			c.GetHeader(HeadTs), c.GetHeader(HeadNonce), c.GetHeader(HeadSign),
+			body,
+		)
+		if err != nil {
+			Logger(c).Warnf("sign deny in %v, client %v from %v: %v", c.FullPath(), id, c.Request.RemoteAddr, err)
+
+			c.JSON(
+				http.StatusUnauthorized,
+				NewFinalRsp(err.Error(), http.StatusUnauthorized),
+			)
+			c.Abort()
+			return
+		}
+
+		c.Set(TagSignClient, id)
+
+		c.Next()
+	}
+
+	return h
+}
@@ -3,17 +3,24 @@ package transfer
 import (
 	"context"
 	"fmt"
+	"io"
 	. "mykit/core/dsp"
 	. "mykit/core/persist"
 	. "mykit/core/types"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 var AppDispatch = map[string]string{}
 
+// routeLock 保护AppDispatch与SmarterRouter, 路由表可在运行时变更
+var routeLock sync.RWMutex
+
 var BeforeSend = func(c *gin.Context) {}
 
 func RegAppDispatch(service string, m ...string) {
@@ -26,19 +33,79 @@ func RegAppDispatch(service string, m ...string) {
 	for _, v := range m {
 		line := fmt.Sprintf("%v -> %v", v, service)
 		log = append(log, line)
+	}
 
+	routeLock.Lock()
+	for _, v := range m {
 		AppDispatch[v] = service
 	}
+	routeLock.Unlock()
 
 	fmt.Println(strings.Join(log, "\n"))
 }
 
+func UnregAppDispatch(app ...string) {
+	routeLock.Lock()
+	for _, v := range app {
+		delete(AppDispatch, v)
+	}
+	routeLock.Unlock()
+}
+
+func GetAppDispatch() map[string]string {
+	res := map[string]string{}
+
+	routeLock.RLock()
+	for k, v := range AppDispatch {
+		res[k] = v
+	}
+	routeLock.RUnlock()
+
+	return res
+}
+
 type SmarterRouter map[string]SmarterService
 
 func (t SmarterRouter) Add(etcd EtcdConfig, app ...string) {
 	for _, v := range app {
-		t[v] = NewSmarterClient(v, etcd)
+		t.AddService(v, NewSmarterClient(v, etcd))
+	}
+}
+
+// AddService 不经etcd直接注册client, 也供测试替换为testkit.MockService
+func (t SmarterRouter) AddService(service string, s SmarterService) {
+	routeLock.Lock()
+	t[service] = s
+	routeLock.Unlock()
+}
+
+func (t SmarterRouter) Get(service string) (s SmarterService, ok bool) {
+	routeLock.RLock()
+	s, ok = t[service]
+	routeLock.RUnlock()
+
+	return
+}
+
+func (t SmarterRouter) Remove(service ...string) {
+	routeLock.Lock()
+	for _, v := range service {
+		delete(t, v)
+	}
+	routeLock.Unlock()
+}
+
+func (t SmarterRouter) Services() []string {
+	routeLock.RLock()
+	res := []string{}
+	for k := range t {
+		res = append(res, k)
 	}
+	routeLock.RUnlock()
+
+	sort.Strings(res)
+
+	return res
 }
 
 func (t SmarterRouter) Rpc(ctx context.Context,
@@ -65,15 +132,40 @@ func (t SmarterRouter) Rpc(ctx context.Context,
 func (t SmarterRouter) Call(ctx context.Context, req *Req) (rsp *Res, err error) {
 	app := req.GetApp()
 
-	target := AppDispatch[app]
-	client, ok := t[target]
+	target := DispatchTarget(app, req.GetMethod())
+	client, ok := t.Get(target)
 	if !ok {
 		msg := fmt.Sprintf(appNotImpl, app)
 		rsp = &Res{Code: http.StatusNotFound, Msg: msg, Data: ByteOfNullJson}
 		return
 	}
 
-	rsp, err = client.Call(ctx, req)
+	policy := RetryFor(app, req.GetMethod())
+
+	return policy.Do(ctx, func(ctx context.Context) (*Res, error) {
+		return t.call(ctx, target, client, req)
+	})
+}
+
+func (t SmarterRouter) call(ctx context.Context, target string, client SmarterService, req *Req) (
+	rsp *Res, err error) {
+	app := req.GetApp()
+
+	breaker := GetBreaker(target)
+	if breaker != nil && !breaker.Allow() {
+		rsp = BreakerOpenRes(app)
+		return
+	}
+
+	t0 := time.Now()
+	rsp, err = client.Call(WithTimeoutMeta(signCtx(ctx, req)), req)
+	breakerDone(ctx, breaker, BreakerFailure(rsp, err), time.Now().Sub(t0))
+
+	if err != nil && ctx.Err() != nil {
+		rsp = TimeoutRes(app)
+		return
+	}
+
 	if err != nil {
 		msg := fmt.Sprintf(callFailed, app)
 		rsp = &Res{Code: http.StatusInternalServerError, Msg: msg, Data: ByteOfNullJson}
@@ -83,10 +175,62 @@ func (t SmarterRouter) Call(ctx context.Context, req *Req) (rsp *Res, err error)
 	return
 }
 
+func (t SmarterRouter) Stream(ctx context.Context, req *Req, send LpcSender) error {
+	app := req.GetApp()
+
+	target := DispatchTarget(app, req.GetMethod())
+	client, ok := t.Get(target)
+	if !ok {
+		msg := fmt.Sprintf(appNotImpl, app)
+		return send(&Res{Code: http.StatusNotFound, Msg: msg, Data: ByteOfNullJson})
+	}
+
+	breaker := GetBreaker(target)
+	if breaker != nil && !breaker.Allow() {
+		return send(BreakerOpenRes(app))
+	}
+
+	stream, err := client.Stream(WithTimeoutMeta(signCtx(ctx, req)), req)
+	breakerDone(ctx, breaker, err != nil, 0)
+
+	if err != nil {
+		msg := fmt.Sprintf(callFailed, app)
+		return send(&Res{Code: http.StatusInternalServerError, Msg: msg, Data: ByteOfNullJson})
+	}
+	defer stream.Close()
+
+	for {
+		rsp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+
+		//中断时补发失败帧, 保证最后一行为状态
+		if err != nil {
+			code := int32(CodeInternal)
+			if ctx.Err() == context.DeadlineExceeded {
+				code = CodeDeadlineExceeded
+			}
+
+			msg := fmt.Sprintf(callFailed, app)
+			send(&Res{Code: code, Msg: msg, Data: ByteOfNullJson})
+
+			return err
+		}
+
+		err = send(rsp)
+		if err != nil {
+			return err
+		}
+	}
+}
+
 func (t SmarterRouter) Handle(c *gin.Context, app, method string) {
-	ctx := CallCtx(c)
+	ctx, cancel := GinCallCtx(c)
+	defer cancel()
+
 	req := NewReqFromGin(c, app, method)
-	rsp, err := t.Call(ctx, req)
+	rsp, err := t.shadowCall(ctx, GinCodec(c), req, t.Call)
 
 	BeforeSend(c)
 
@@ -102,10 +246,12 @@ func (t SmarterRouter) GinHandler() func(c *gin.Context) {
 }
 
 func (t SmarterRouter) Handle2(c *gin.Context, app, method string) {
-	ctx := CallCtx(c)
+	ctx, cancel := GinCallCtx(c)
+	defer cancel()
+
 	req := NewReqFromGin(c, app, method)
 
-	rsp, err := t.Call(ctx, req)
+	rsp, err := t.shadowCall(ctx, GinCodec(c), req, t.Call)
 
 	BeforeSend(c)
 
@@ -121,18 +267,27 @@ func (t SmarterRouter) GinHandler2() func(c *gin.Context) {
 }
 
 func (t SmarterRouter) LpcCall(c *gin.Context, app, method string) {
-	ctx := CallCtx(c)
+	ctx, cancel := GinCallCtx(c)
+	defer cancel()
+
 	req := NewReqFromGin(c, app, method)
 
+	rsp, err := t.shadowCall(ctx, GinCodec(c), req, t.dispatch)
+
+	BeforeSend(c)
+
+	SendRsp2(c, req, rsp, err)
+}
+
+// dispatch 本地已注册的app走DISP, 否则按AppDispatch转发
+func (t SmarterRouter) dispatch(ctx context.Context, req *Req) (*Res, error) {
 	rsp := &Res{}
 	err := DISP.Call(ctx, req, rsp)
 	if rsp.Code == CodeUnimplemented {
-		rsp, err = t.Call(ctx, req)
+		return t.Call(ctx, req)
 	}
 
-	BeforeSend(c)
-
-	SendRsp2(c, req, rsp, err)
+	return rsp, err
 }
 
 func (t SmarterRouter) LpcHandler() func(c *gin.Context) {
@@ -142,3 +297,76 @@ func (t SmarterRouter) LpcHandler() func(c *gin.Context) {
 
 	return h
 }
+
+// StreamCall 以chunked json lines转发流式结果, 每行为一个{code,msg,data}, 最后一行为状态(成功时code为204)
+func (t SmarterRouter) StreamCall(c *gin.Context, app, method string) {
+	ctx, cancel := GinCallCtx(c)
+	defer cancel()
+
+	req := NewReqFromGin(c, app, method)
+
+	codec := GinCodec(c)
+	err := transcodeParam(codec, req)
+	if err != nil {
+		SendRsp2(c, req, BadRequestRes(CodeInvalidArgument), nil)
+		return
+	}
+
+	ctx = WithCodec(ctx, codec)
+
+	BeforeSend(c)
+
+	c.Header(ContentType, JsonLineType)
+	c.Status(http.StatusOK)
+
+	send := func(rsp *Res) error {
+		rsp = transcodeRes(codec, req, rsp)
+
+		c.Set(LogFiledCode, int(rsp.GetCode()))
+		c.Set(LogFiledMsg, rsp.GetMsg())
+
+		line := MustJsonMarshal(FinalRsp2{
+			Code: rsp.GetCode(),
+			Msg:  rsp.GetMsg(),
+			Data: EnsureJsonByte(rsp.GetData()),
+		})
+
+		_, err := c.Writer.Write(append(line, '\n'))
+		if err != nil {
+			return err
+		}
+
+		c.Writer.Flush()
+
+		return ctx.Err()
+	}
+
+	_, local := DISP.Get(app)
+	if local {
+		err = DISP.StreamTo(ctx, req, send)
+	} else {
+		err = t.Stream(ctx, req, send)
+	}
+
+	if err != nil {
+		detail := map[string]string{
+			TagApp:    app,
+			TagMethod: method,
+		}
+
+		ZapFailed(LogS1,
+			LogEvent(LogMsgGateway),
+			LogProcRpc(),
+			LogDetail(detail),
+			LogError(err),
+		)
+	}
+}
+
+func (t SmarterRouter) StreamHandler() func(c *gin.Context) {
+	var h = func(c *gin.Context) {
+		t.StreamCall(c, c.Param(TagApp), c.Param(TagMethod))
+	}
+
+	return h
+}
@@ -160,11 +160,13 @@ var file_smarter_proto_rawDesc = []byte{
 	0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x73,
 	0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x73, 0x67, 0x12, 0x12, 0x0a, 0x04,
 	0x64, 0x61, 0x74, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61,
-	0x32, 0x2f, 0x0a, 0x07, 0x53, 0x6d, 0x61, 0x72, 0x74, 0x65, 0x72, 0x12, 0x24, 0x0a, 0x04, 0x43,
+	0x32, 0x59, 0x0a, 0x07, 0x53, 0x6d, 0x61, 0x72, 0x74, 0x65, 0x72, 0x12, 0x24, 0x0a, 0x04, 0x43,
 	0x61, 0x6c, 0x6c, 0x12, 0x0c, 0x2e, 0x73, 0x6d, 0x61, 0x72, 0x74, 0x65, 0x72, 0x2e, 0x52, 0x65,
 	0x71, 0x1a, 0x0c, 0x2e, 0x73, 0x6d, 0x61, 0x72, 0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x73, 0x22,
-	0x00, 0x42, 0x0a, 0x5a, 0x08, 0x2f, 0x73, 0x6d, 0x61, 0x72, 0x74, 0x65, 0x72, 0x62, 0x06, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x00, 0x12, 0x28, 0x0a, 0x06, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x0c, 0x2e, 0x73, 0x6d,
+	0x61, 0x72, 0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x71, 0x1a, 0x0c, 0x2e, 0x73, 0x6d, 0x61, 0x72,
+	0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x73, 0x22, 0x00, 0x30, 0x01, 0x42, 0x0a, 0x5a, 0x08, 0x2f,
+	0x73, 0x6d, 0x61, 0x72, 0x74, 0x65, 0x72, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -186,9 +188,11 @@ var file_smarter_proto_goTypes = []interface{}{
 }
 var file_smarter_proto_depIdxs = []int32{
 	0, // 0: smarter.Smarter.Call:input_type -> smarter.Req
-	1, // 1: smarter.Smarter.Call:output_type -> smarter.Res
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
+	0, // 1: smarter.Smarter.Stream:input_type -> smarter.Req
+	1, // 2: smarter.Smarter.Call:output_type -> smarter.Res
+	1, // 3: smarter.Smarter.Stream:output_type -> smarter.Res
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
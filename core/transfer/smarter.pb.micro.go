@@ -41,6 +41,7 @@ func NewSmarterEndpoints() []*api.Endpoint {
 
 type SmarterService interface {
 	Call(ctx context.Context, in *Req, opts ...client.CallOption) (*Res, error)
+	Stream(ctx context.Context, in *Req, opts ...client.CallOption) (Smarter_StreamService, error)
 }
 
 type smarterService struct {
@@ -65,15 +66,66 @@ func (c *smarterService) Call(ctx context.Context, in *Req, opts ...client.CallO
 	return out, nil
 }
 
+func (c *smarterService) Stream(ctx context.Context, in *Req, opts ...client.CallOption) (Smarter_StreamService, error) {
+	req := c.c.NewRequest(c.name, "Smarter.Stream", &Req{})
+	stream, err := c.c.Stream(ctx, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(in); err != nil {
+		return nil, err
+	}
+	return &smarterServiceStream{stream}, nil
+}
+
+type Smarter_StreamService interface {
+	Context() context.Context
+	SendMsg(interface{}) error
+	RecvMsg(interface{}) error
+	Close() error
+	Recv() (*Res, error)
+}
+
+type smarterServiceStream struct {
+	stream client.Stream
+}
+
+func (x *smarterServiceStream) Close() error {
+	return x.stream.Close()
+}
+
+func (x *smarterServiceStream) Context() context.Context {
+	return x.stream.Context()
+}
+
+func (x *smarterServiceStream) SendMsg(m interface{}) error {
+	return x.stream.Send(m)
+}
+
+func (x *smarterServiceStream) RecvMsg(m interface{}) error {
+	return x.stream.Recv(m)
+}
+
+func (x *smarterServiceStream) Recv() (*Res, error) {
+	m := new(Res)
+	err := x.stream.Recv(m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for Smarter service
 
 type SmarterHandler interface {
 	Call(context.Context, *Req, *Res) error
+	Stream(context.Context, *Req, Smarter_StreamStream) error
 }
 
 func RegisterSmarterHandler(s server.Server, hdlr SmarterHandler, opts ...server.HandlerOption) error {
 	type smarter interface {
 		Call(ctx context.Context, in *Req, out *Res) error
+		Stream(ctx context.Context, stream server.Stream) error
 	}
 	type Smarter struct {
 		smarter
@@ -89,3 +141,43 @@ type smarterHandler struct {
 func (h *smarterHandler) Call(ctx context.Context, in *Req, out *Res) error {
 	return h.SmarterHandler.Call(ctx, in, out)
 }
+
+func (h *smarterHandler) Stream(ctx context.Context, stream server.Stream) error {
+	m := new(Req)
+	if err := stream.Recv(m); err != nil {
+		return err
+	}
+	return h.SmarterHandler.Stream(ctx, m, &smarterStreamStream{stream})
+}
+
+type Smarter_StreamStream interface {
+	Context() context.Context
+	SendMsg(interface{}) error
+	RecvMsg(interface{}) error
+	Close() error
+	Send(*Res) error
+}
+
+type smarterStreamStream struct {
+	stream server.Stream
+}
+
+func (x *smarterStreamStream) Close() error {
+	return x.stream.Close()
+}
+
+func (x *smarterStreamStream) Context() context.Context {
+	return x.stream.Context()
+}
+
+func (x *smarterStreamStream) SendMsg(m interface{}) error {
+	return x.stream.Send(m)
+}
+
+func (x *smarterStreamStream) RecvMsg(m interface{}) error {
+	return x.stream.Recv(m)
+}
+
+func (x *smarterStreamStream) Send(m *Res) error {
+	return x.stream.Send(m)
+}
@@ -20,7 +20,8 @@ import (
 const _ = grpc.SupportPackageIsVersion5
 
 const (
-	Smarter_Call_FullMethodName = "/smarter.Smarter/Call"
+	Smarter_Call_FullMethodName   = "/smarter.Smarter/Call"
+	Smarter_Stream_FullMethodName = "/smarter.Smarter/Stream"
 )
 
 // SmarterClient is the client API for Smarter service.
@@ -28,6 +29,7 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type SmarterClient interface {
 	Call(ctx context.Context, in *Req, opts ...grpc.CallOption) (*Res, error)
+	Stream(ctx context.Context, in *Req, opts ...grpc.CallOption) (Smarter_StreamClient, error)
 }
 
 type smarterClient struct {
@@ -47,11 +49,44 @@ func (c *smarterClient) Call(ctx context.Context, in *Req, opts ...grpc.CallOpti
 	return out, nil
 }
 
+func (c *smarterClient) Stream(ctx context.Context, in *Req, opts ...grpc.CallOption) (Smarter_StreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Smarter_ServiceDesc.Streams[0], Smarter_Stream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &smarterStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Smarter_StreamClient interface {
+	Recv() (*Res, error)
+	grpc.ClientStream
+}
+
+type smarterStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *smarterStreamClient) Recv() (*Res, error) {
+	m := new(Res)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // SmarterServer is the server API for Smarter service.
 // All implementations must embed UnimplementedSmarterServer
 // for forward compatibility
 type SmarterServer interface {
 	Call(context.Context, *Req) (*Res, error)
+	Stream(*Req, Smarter_StreamServer) error
 	mustEmbedUnimplementedSmarterServer()
 }
 
@@ -62,6 +97,9 @@ type UnimplementedSmarterServer struct {
 func (UnimplementedSmarterServer) Call(context.Context, *Req) (*Res, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Call not implemented")
 }
+func (UnimplementedSmarterServer) Stream(*Req, Smarter_StreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method Stream not implemented")
+}
 func (UnimplementedSmarterServer) mustEmbedUnimplementedSmarterServer() {}
 
 // UnsafeSmarterServer may be embedded to opt out of forward compatibility for this service.
@@ -93,6 +131,27 @@ func _Smarter_Call_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Smarter_Stream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Req)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SmarterServer).Stream(m, &smarterStreamServer{stream})
+}
+
+type Smarter_StreamServer interface {
+	Send(*Res) error
+	grpc.ServerStream
+}
+
+type smarterStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *smarterStreamServer) Send(m *Res) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // Smarter_ServiceDesc is the grpc.ServiceDesc for Smarter service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -105,6 +164,12 @@ var Smarter_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _Smarter_Call_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Stream",
+			Handler:       _Smarter_Stream_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "smarter.proto",
 }
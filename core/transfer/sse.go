@@ -0,0 +1,264 @@
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	. "mykit/core/dsp"
+	. "mykit/core/types"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SSE: 经流式调用执行方法, 方法内ReportProgress写出的进度以RspCodeProgress帧回传
+// 事件依次为 progress* -> result+ -> done, 调用失败时以error结束; 客户端断开即取消ctx
+// 每次请求都会重新执行方法, 不支持Last-Event-ID续传, 客户端收到done或error后应主动close, 否则EventSource会重连
+
+const (
+	EventStreamType  = "text/event-stream; charset=utf-8"
+	SseEventProgress = "progress"
+	SseEventResult   = "result"
+	SseEventError    = "error"
+	SseEventDone     = "done"
+	sseParam         = "param"
+)
+
+var (
+	// SseKeepAlive 注释行心跳间隔, 防止代理断开空闲连接
+	SseKeepAlive = time.Second * 15
+)
+
+type progressKey struct{}
+
+type Progress struct {
+	Percent float64         `json:"percent"`
+	Msg     string          `json:"msg"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (t Progress) Res() *Res {
+	res := &Res{
+		Code: RspCodeProgress,
+		Msg:  t.Msg,
+		Data: MustJsonMarshal(t),
+	}
+
+	return res
+}
+
+type ProgressReporter func(p Progress) error
+
+func WithProgress(ctx context.Context, f ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressKey{}, f)
+}
+
+// ReportProgress 非流式调用时为空操作; 返回err时客户端已断开, handler应尽快退出
+func ReportProgress(ctx context.Context, percent float64, msg string, data ...interface{}) error {
+	f, ok := ctx.Value(progressKey{}).(ProgressReporter)
+	if !ok {
+		return nil
+	}
+
+	p := Progress{
+		Percent: percent,
+		Msg:     msg,
+	}
+
+	if len(data) > 0 {
+		p.Data = JsonRawMessage(data[0])
+	}
+
+	return f(p)
+}
+
+func IsProgressRes(res *Res) bool {
+	return res.GetCode() == RspCodeProgress
+}
+
+// progressSender 串行化进度与结果帧, 方法返回后不再接受进度
+type progressSender struct {
+	sync.Mutex
+	send   LpcSender
+	closed bool
+}
+
+func (t *progressSender) Send(res *Res) error {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.closed {
+		return ErrOffline
+	}
+
+	return t.send(res)
+}
+
+func (t *progressSender) Report(p Progress) error {
+	return t.Send(p.Res())
+}
+
+func (t *progressSender) Close() {
+	t.Lock()
+	t.closed = true
+	t.Unlock()
+}
+
+type sseWriter struct {
+	sync.Mutex
+	c *gin.Context
+}
+
+func (t *sseWriter) write(event string, data []byte) error {
+	t.Lock()
+	defer t.Unlock()
+
+	_, err := fmt.Fprintf(t.c.Writer, "event: %s\ndata: %s\n\n", event, data)
+	if err != nil {
+		return err
+	}
+
+	t.c.Writer.Flush()
+
+	return nil
+}
+
+func (t *sseWriter) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(SseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			t.Lock()
+			_, err := t.c.Writer.WriteString(": ping\n\n")
+			if err == nil {
+				t.c.Writer.Flush()
+			}
+			t.Unlock()
+
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func sseReq(c *gin.Context, app, method string) *Req {
+	req := NewReqFromGin(c, app, method)
+
+	//EventSource只能GET, 参数取自query
+	if c.Request.Method == http.MethodGet {
+		req.Param = StringToBytes(c.Query(sseParam))
+	}
+
+	return req
+}
+
+func (t SmarterRouter) SseCall(c *gin.Context, app, method string) {
+	ctx, cancel := GinCallCtx(c)
+	defer cancel()
+
+	req := sseReq(c, app, method)
+
+	BeforeSend(c)
+
+	h := c.Writer.Header()
+	h.Set(ContentType, EventStreamType)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	w := &sseWriter{c: c}
+
+	//handler返回前等待心跳退出, 避免写已回收的Writer
+	alive, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.keepAlive(alive)
+	}()
+	defer func() {
+		stop()
+		<-done
+	}()
+
+	send := func(rsp *Res) error {
+		//结束帧统一在返回后以done事件下发
+		if IsStreamEnd(rsp) {
+			return nil
+		}
+
+		if IsProgressRes(rsp) {
+			return w.write(SseEventProgress, EnsureJsonByte(rsp.GetData()))
+		}
+
+		c.Set(LogFiledCode, int(rsp.GetCode()))
+		c.Set(LogFiledMsg, rsp.GetMsg())
+
+		err := w.write(SseEventResult, MustJsonMarshal(FinalRsp2{
+			Code: rsp.GetCode(),
+			Msg:  rsp.GetMsg(),
+			Data: EnsureJsonByte(rsp.GetData()),
+		}))
+		if err != nil {
+			return err
+		}
+
+		return ctx.Err()
+	}
+
+	var err error
+
+	_, local := DISP.Get(app)
+	if local {
+		err = DISP.StreamTo(ctx, req, send)
+	} else {
+		err = t.Stream(ctx, req, send)
+	}
+
+	//客户端已断开
+	if c.Request.Context().Err() != nil {
+		return
+	}
+
+	if err == nil {
+		w.write(SseEventDone, ByteOfNullJson)
+		return
+	}
+
+	ZapFailed(LogS1,
+		LogEvent(LogMsgGateway),
+		LogProcRpc(),
+		LogDetail(map[string]string{
+			TagApp:    app,
+			TagMethod: method,
+		}),
+		LogError(err),
+	)
+
+	code := int32(CodeInternal)
+	if ctx.Err() == context.DeadlineExceeded {
+		code = CodeDeadlineExceeded
+	}
+
+	w.write(SseEventError, MustJsonMarshal(FinalRsp2{
+		Code: code,
+		Msg:  fmt.Sprintf(callFailed, app),
+		Data: ByteOfNullJson,
+	}))
+}
+
+func (t SmarterRouter) SseHandler() func(c *gin.Context) {
+	var h = func(c *gin.Context) {
+		t.SseCall(c, c.Param(TagApp), c.Param(TagMethod))
+	}
+
+	return h
+}
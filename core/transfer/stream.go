@@ -0,0 +1,416 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	. "mykit/core/dsp"
+	. "mykit/core/types"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	streamNone int8 = iota
+	streamCallback
+	streamChan
+)
+
+var (
+	StreamChanSize = 16
+)
+
+// LpcSender 逐条发送流式结果, 返回err时handler应尽快退出
+type LpcSender func(res *Res) error
+
+// IsStreamMethod 流式方法形如 func(ctx, *Param, func(*Item) error) error
+// 或 func(ctx, *Param, chan<- *Item) error, chan由框架在方法返回后关闭
+func IsStreamMethod(m reflect.Type) bool {
+	return m.NumIn() == 3 && m.NumOut() == 1
+}
+
+func checkStreamMethod(m reflect.Type) (code int32) {
+	code++
+
+	//校验入参1
+	code++
+	if !IsContextKind(m.In(0)) {
+		return
+	}
+
+	//校验入参2
+	code++
+	if !validInput(m.In(1)) {
+		return
+	}
+
+	//校验sender
+	code++
+	kind, _ := parseSender(m.In(2))
+	if kind == streamNone {
+		return
+	}
+
+	//校验出参
+	code++
+	if !IsErrorKind(m.Out(0)) {
+		return
+	}
+
+	return 0
+}
+
+func parseSender(t reflect.Type) (kind int8, item reflect.Type) {
+	switch t.Kind() {
+	case reflect.Func:
+		if t.NumIn() == 1 && t.NumOut() == 1 && validOutput(t.In(0)) && IsErrorKind(t.Out(0)) {
+			return streamCallback, t.In(0)
+		}
+
+	case reflect.Chan:
+		if t.ChanDir()&reflect.SendDir != 0 && validOutput(t.Elem()) {
+			return streamChan, t.Elem()
+		}
+	}
+
+	return streamNone, nil
+}
+
+func (t LpcMeta) Stream() bool {
+	return t.stream != streamNone
+}
+
+func StreamOnlyRes(method string) *Res {
+	res := &Res{
+		Code: CodeFailedPrecond,
+		Msg:  fmt.Sprintf(streamOnly, method),
+		Data: ByteOfNullJson,
+	}
+
+	return res
+}
+
+// StreamEndRes 流式调用成功结束的最后一帧
+func StreamEndRes() *Res {
+	res := &Res{
+		Code: RspCodeStreamEnd,
+		Msg:  RspMsgSuccess,
+		Data: ByteOfNullJson,
+	}
+
+	return res
+}
+
+func IsStreamEnd(res *Res) bool {
+	return res.GetCode() == RspCodeStreamEnd
+}
+
+// Stream 最后一帧总是状态: 成功为StreamEndRes, 失败为非200的Res; 非流式方法的结果在其前单独一帧
+func (t *Lpc) Stream(ctx context.Context, req *Req, send LpcSender) (err error) {
+	method := req.GetMethod()
+	meta, ok := t.lookup(ctx, method)
+	if !ok {
+		return send(MethodNotImplRes(method))
+	}
+
+	if !meta.policy.allow(ctx) {
+		return send(RoleRequiredRes(method, meta.policy.Role))
+	}
+
+	ctx, cancel := meta.policy.withTimeout(ctx)
+	defer cancel()
+
+	t.RWMutex.RLock()
+	i := t.i
+	t.RWMutex.RUnlock()
+
+	streamed := false
+	var sendErr error
+
+	//进度与流式结果共用同一发送通道, 方法返回后关闭
+	ps := &progressSender{send: send}
+	ctx = WithProgress(ctx, ps.Report)
+
+	var final LpcInvoker = func(ctx context.Context, req *Req) (*Res, error) {
+		defer ps.Close()
+
+		if !meta.Stream() {
+			code, msg, data, err := t.call(ctx, meta, req)
+			if code != http.StatusOK || IsJsonEncoder(GetCodec(ctx)) {
+				data = EnsureJsonByte(data)
+			}
+
+			return &Res{Code: code, Msg: msg, Data: data}, err
+		}
+
+		streamed = true
+
+		code, msg, err := t.stream(ctx, meta, req, func(res *Res) error {
+			sendErr = ps.Send(res)
+			return sendErr
+		})
+
+		return &Res{Code: code, Msg: msg, Data: ByteOfNullJson}, err
+	}
+
+	invoker := chainLpcInterceptor(meta, final, globalInterceptors(), i)
+
+	res, err := invoker(ctx, req)
+	if sendErr != nil {
+		return sendErr
+	}
+
+	if res == nil {
+		res = ShortCircuitRes(method, err)
+	}
+
+	if res.GetCode() != http.StatusOK {
+		return send(res)
+	}
+
+	if !streamed {
+		err = send(res)
+		if err != nil {
+			return err
+		}
+	}
+
+	return send(StreamEndRes())
+}
+
+func (t *Lpc) stream(ctx context.Context, meta LpcMeta, req *Req, send LpcSender) (
+	code int32, msg string, err error) {
+	method := req.GetMethod()
+	h := meta.New(ctx)
+	f := reflect.ValueOf(h).Method(meta.n)
+	enc := GetCodec(ctx)
+
+	param, code, msg, err := parseParam(enc, meta, req)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var l sync.Mutex
+	var sendErr, encErr error
+	emit := func(v reflect.Value) error {
+		l.Lock()
+		defer l.Unlock()
+
+		if sendErr != nil {
+			return sendErr
+		}
+
+		data, err := encodeData(enc, v)
+		if err != nil {
+			encErr = err
+			sendErr = err
+			cancel()
+			return err
+		}
+
+		if IsJsonEncoder(enc) {
+			data = EnsureJsonByte(data)
+		}
+
+		sendErr = send(&Res{Code: http.StatusOK, Msg: RspMsgSuccess, Data: data})
+		if sendErr != nil {
+			cancel()
+		}
+
+		return sendErr
+	}
+
+	//chan模式下待发送项在返回后才排空, 编码失败须在其后判定
+	defer func() {
+		if encErr != nil {
+			code = CodeInternal
+			msg = fmt.Sprintf(callFailed, method)
+			err = encErr
+		}
+	}()
+
+	sender := reflect.Value{}
+	senderType := f.Type().In(2)
+
+	switch meta.stream {
+	case streamCallback:
+		sender = reflect.MakeFunc(senderType, func(args []reflect.Value) []reflect.Value {
+			res := reflect.New(senderType.Out(0)).Elem()
+			err := emit(args[0])
+			if err != nil {
+				res.Set(reflect.ValueOf(err))
+			}
+
+			return []reflect.Value{res}
+		})
+
+	case streamChan:
+		ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, meta.item), StreamChanSize)
+		sender = ch.Convert(senderType)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			for {
+				v, ok := ch.Recv()
+				if !ok {
+					return
+				}
+
+				emit(v)
+			}
+		}()
+
+		defer func() {
+			closeChan(ch)
+			<-done
+		}()
+	}
+
+	//执行方法
+	callRes := f.Call([]reflect.Value{
+		reflect.ValueOf(ctx),
+		reflect.ValueOf(param),
+		sender,
+	})
+
+	//处理执行结果
+	err, ok := callRes[0].Interface().(error)
+	if ok && err != nil {
+		var v *ErrorCode
+		v, ok = err.(*ErrorCode)
+		if ok {
+			code = v.Code()
+			msg = v.Error()
+		} else {
+			code = CodeInternal
+			msg = fmt.Sprintf(callFailed, method)
+		}
+
+		return
+	}
+
+	code = http.StatusOK
+	msg = RspMsgSuccess
+
+	return
+}
+
+// closeChan handler可能已自行关闭chan
+func closeChan(ch reflect.Value) {
+	defer func() {
+		recover()
+	}()
+
+	ch.Close()
+}
+
+func lpcStream(lpc *Lpc, ctx context.Context, req *Req, send LpcSender) (err error) {
+	var cost time.Duration
+	var n int
+
+	method := req.GetMethod()
+	user := GetUser(ctx)
+	from := GetFrom(ctx)
+
+	f := []zap.Field{
+		LogEventGrpc(),
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr, ok := r.(error)
+			if !ok {
+				panicErr = fmt.Errorf("%v", r)
+			}
+
+			buf := make([]byte, OutputPanicStackSize)
+			buf = buf[:runtime.Stack(buf, false)]
+
+			Logger(ctx).Failed(
+				LogEvent("panic occur @ lpc stream"),
+				LogProcessor(method),
+				LogUser(user), LogFrom(from),
+				LogBinary(buf),
+				LogError(panicErr),
+			)
+
+			err = send(&Res{
+				Code: CodeInternal,
+				Msg:  fmt.Sprintf(callFailed, method),
+				Data: ByteOfNullJson,
+			})
+			return
+		}
+
+		Logger(ctx).Output(method, err,
+			append(f,
+				LogProcSend(),
+				LogDuration(cost),
+				LogDetail(map[string]interface{}{"chunk": n}),
+				LogUser(user), LogFrom(from),
+			)...,
+		)
+	}()
+
+	Logger(ctx).Info(method,
+		append(f,
+			LogProcRecv(),
+			LogUser(user), LogFrom(from),
+			LogBinary(req.GetParam()),
+		)...,
+	)
+
+	t0 := time.Now()
+	err = lpc.Stream(ctx, req, func(res *Res) error {
+		n++
+		return send(res)
+	})
+	cost = time.Now().Sub(t0)
+
+	return
+}
+
+func (t *LpcDispatch) Stream(ctx context.Context, input *Req, stream Smarter_StreamStream) error {
+	return t.StreamTo(ctx, input, stream.Send)
+}
+
+func (t *LpcDispatch) StreamTo(ctx context.Context, input *Req, send LpcSender) error {
+	ctx, cancel := WithMdDeadline(HandleMd(ctx))
+	defer cancel()
+
+	app := input.GetApp()
+
+	//入站user/role可被伪造, 由rbacCheck据此拒绝
+	ctx = trustMd(ctx, app, input.GetMethod())
+
+	lpc, ok := t.Get(app)
+	if !ok {
+		return send(AppNotImplRes(app))
+	}
+
+	ctx, input = lpc.resolveReq(ctx, input)
+
+	if t.Disabled(app, input.GetMethod()) {
+		return send(DisabledRes(app, input.GetMethod()))
+	}
+
+	deny := rbacCheck(ctx, app, input.GetMethod())
+	if deny != nil {
+		return send(deny)
+	}
+
+	if ctx.Err() != nil {
+		return send(TimeoutRes(input.GetMethod()))
+	}
+
+	return lpcStream(lpc, ctx, input, send)
+}
@@ -0,0 +1,175 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	. "mykit/core/dsp"
+	. "mykit/core/persist"
+	. "mykit/core/types"
+	"sort"
+
+	"github.com/coreos/etcd/mvcc/mvccpb"
+)
+
+// LpcSwitch 运行时关闭的app/method, 存于etcd
+type LpcSwitch struct {
+	App    []string            `json:"app"`
+	Method map[string][]string `json:"method"`
+}
+
+func NewLpcSwitch() LpcSwitch {
+	res := LpcSwitch{
+		App:    []string{},
+		Method: map[string][]string{},
+	}
+
+	return res
+}
+
+func (t LpcSwitch) Clone() LpcSwitch {
+	res := NewLpcSwitch()
+	res.App = append(res.App, t.App...)
+
+	for k, v := range t.Method {
+		res.Method[k] = append([]string{}, v...)
+	}
+
+	return res
+}
+
+func (t *LpcDispatch) Switch() LpcSwitch {
+	t.RLock()
+	defer t.RUnlock()
+
+	return t.off.Clone()
+}
+
+func (t LpcSwitch) normalize() LpcSwitch {
+	res := NewLpcSwitch()
+
+	res.App = SetStrList(t.App)
+	sort.Strings(res.App)
+
+	for k, v := range t.Method {
+		m := SetStrList(v)
+		if len(m) == 0 {
+			continue
+		}
+
+		sort.Strings(m)
+		res.Method[k] = m
+	}
+
+	return res
+}
+
+func (t *LpcDispatch) ApplySwitch(sw LpcSwitch) {
+	sw = sw.normalize()
+
+	t.Lock()
+	t.off = sw
+	t.Unlock()
+}
+
+func (t *LpcDispatch) updateSwitch(f func(sw *LpcSwitch)) {
+	t.Lock()
+	defer t.Unlock()
+
+	sw := t.off.Clone()
+	f(&sw)
+	t.off = sw.normalize()
+}
+
+func (t *LpcDispatch) Disabled(app, method string) bool {
+	t.RLock()
+	defer t.RUnlock()
+
+	off := t.off
+	if InStrList(off.App, app) {
+		return true
+	}
+
+	//关闭method时其全部版本一并关闭
+	name, _ := ParseMethodVersion(method)
+
+	return InStrList(off.Method[app], method) || InStrList(off.Method[app], name)
+}
+
+func (t *LpcDispatch) DisableApp(app ...string) {
+	t.updateSwitch(func(sw *LpcSwitch) {
+		sw.App = append(sw.App, app...)
+	})
+}
+
+func (t *LpcDispatch) EnableApp(app ...string) {
+	t.updateSwitch(func(sw *LpcSwitch) {
+		sw.App = StrListSub(sw.App, app)
+	})
+}
+
+func (t *LpcDispatch) Disable(app string, method ...string) {
+	t.updateSwitch(func(sw *LpcSwitch) {
+		sw.Method[app] = append(sw.Method[app], method...)
+	})
+}
+
+func (t *LpcDispatch) Enable(app string, method ...string) {
+	t.updateSwitch(func(sw *LpcSwitch) {
+		sw.Method[app] = StrListSub(sw.Method[app], method)
+	})
+}
+
+// WatchSwitch 先加载key的当前值, 再监听后续变更; key被删除时全部恢复
+func (t *LpcDispatch) WatchSwitch(etcd *EtcdContext, key string) {
+	sw := NewLpcSwitch()
+	err := etcd.Get(Ctx, key, &sw)
+	if err == nil {
+		t.ApplySwitch(sw)
+	}
+
+	put := func(kv *mvccpb.KeyValue) {
+		if string(kv.Key) != key {
+			return
+		}
+
+		sw := NewLpcSwitch()
+		err := UnmarshalJson(kv.Value, &sw)
+		if err != nil {
+			return
+		}
+
+		t.applySwitch(sw)
+	}
+
+	del := func(kv *mvccpb.KeyValue) {
+		if string(kv.Key) == key {
+			t.applySwitch(NewLpcSwitch())
+		}
+	}
+
+	go etcd.WatchEvent(key, put, del)
+}
+
+func (t *LpcDispatch) applySwitch(sw LpcSwitch) {
+	t.ApplySwitch(sw)
+
+	LogS1.Info(LogMsgSetup,
+		LogEvent("lpc"),
+		LogProcessor("switch"),
+		LogDetail(sw),
+	)
+}
+
+func PutLpcSwitch(ctx context.Context, etcd *EtcdContext, key string, sw LpcSwitch) error {
+	return etcd.Put(ctx, key, sw)
+}
+
+func DisabledRes(app, method string) *Res {
+	res := &Res{
+		Code: CodeUnavailable,
+		Msg:  fmt.Sprintf(methodDisabled, app, method),
+		Data: ByteOfNullJson,
+	}
+
+	return res
+}
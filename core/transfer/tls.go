@@ -0,0 +1,321 @@
+package transfer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	. "mykit/core/dsp"
+	. "mykit/core/persist"
+	. "mykit/core/types"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"github.com/gin-gonic/gin"
+)
+
+// TLS: 握手时取当前证书, 证书文件变更或etcd中的证书更新后即时生效, 无需重启
+// 配置ClientCa后启用mTLS, 校验通过的客户端证书标识写入gin context的TagClientCert
+
+const (
+	TagClientCert     = "clientCert"
+	ClientAuthRequest = "request" //客户端证书可选, 提供时必须可信
+	ClientAuthRequire = "require"
+)
+
+var (
+	CertReloadInterval = time.Second * 30
+
+	ErrNoCert     = errors.New("no certificate")
+	ErrClientAuth = errors.New("client auth must be require or request")
+)
+
+// CertPair etcd中证书的值
+type CertPair struct {
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+}
+
+type CertStore struct {
+	sync.RWMutex
+	cert *tls.Certificate
+	pool *x509.CertPool
+	auth tls.ClientAuthType
+	conf GinConfig
+	mod  map[string]time.Time
+}
+
+// NewCertStore ClientAuth为空时按require处理, 其它未知值返回ErrClientAuth
+func NewCertStore(conf GinConfig) (*CertStore, error) {
+	res := &CertStore{
+		conf: conf,
+		auth: tls.RequireAndVerifyClientCert,
+		mod:  map[string]time.Time{},
+	}
+
+	switch conf.ClientAuth {
+	case "", ClientAuthRequire:
+	case ClientAuthRequest:
+		res.auth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, ErrClientAuth
+	}
+
+	return res, nil
+}
+
+func (t *CertStore) SetPem(cert, key []byte) error {
+	pair, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		return err
+	}
+
+	t.Lock()
+	t.cert = &pair
+	t.Unlock()
+
+	return nil
+}
+
+func (t *CertStore) SetCa(raw []byte) error {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw) {
+		return ErrInvalidParam
+	}
+
+	t.Lock()
+	t.pool = pool
+	t.Unlock()
+
+	return nil
+}
+
+func (t *CertStore) files() []string {
+	res := []string{}
+	if t.conf.CertEtcd == "" {
+		res = append(res, t.conf.Cert, t.conf.Key)
+	}
+
+	if t.conf.ClientCa != "" {
+		res = append(res, t.conf.ClientCa)
+	}
+
+	return res
+}
+
+// Load 读取证书与CA文件, 证书来自etcd时只读CA; 失败时不记录修改时间, 下次检查重试
+func (t *CertStore) Load() error {
+	mod := map[string]time.Time{}
+	for _, v := range t.files() {
+		info, err := os.Stat(v)
+		if err != nil {
+			return err
+		}
+
+		mod[v] = info.ModTime()
+	}
+
+	err := t.load()
+	if err != nil {
+		return err
+	}
+
+	t.mod = mod
+
+	return nil
+}
+
+func (t *CertStore) load() error {
+	if t.conf.CertEtcd == "" {
+		cert, err := os.ReadFile(t.conf.Cert)
+		if err != nil {
+			return err
+		}
+
+		key, err := os.ReadFile(t.conf.Key)
+		if err != nil {
+			return err
+		}
+
+		err = t.SetPem(cert, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	if t.conf.ClientCa == "" {
+		return nil
+	}
+
+	ca, err := os.ReadFile(t.conf.ClientCa)
+	if err != nil {
+		return err
+	}
+
+	return t.SetCa(ca)
+}
+
+func (t *CertStore) changed() bool {
+	for _, v := range t.files() {
+		info, err := os.Stat(v)
+		if err == nil && !info.ModTime().Equal(t.mod[v]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Watch 定时检查文件变更, 加载失败时沿用旧证书
+func (t *CertStore) Watch(ctx context.Context) {
+	ticker := time.NewTicker(CertReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if !t.changed() {
+				continue
+			}
+
+			err := t.Load()
+			t.logReload(t.conf.Cert, err)
+		}
+	}
+}
+
+// WatchEtcd 先加载key的当前值, 再监听后续变更
+func (t *CertStore) WatchEtcd(etcd *EtcdContext, key string) error {
+	pair := CertPair{}
+	err := etcd.Get(Ctx, key, &pair)
+	if err == nil {
+		err = t.SetPem(StringToBytes(pair.Cert), StringToBytes(pair.Key))
+	}
+
+	go etcd.Watch(key, func(kv *mvccpb.KeyValue) {
+		if string(kv.Key) != key {
+			return
+		}
+
+		pair := CertPair{}
+		err := UnmarshalJson(kv.Value, &pair)
+		if err == nil {
+			err = t.SetPem(StringToBytes(pair.Cert), StringToBytes(pair.Key))
+		}
+
+		t.logReload(key, err)
+	})
+
+	return err
+}
+
+func (t *CertStore) logReload(src string, err error) {
+	if err != nil {
+		LogS1.Warn(LogMsgSetup,
+			LogEvent("cert"),
+			LogProcessor(src),
+			LogError(err),
+		)
+		return
+	}
+
+	LogS1.Info(LogMsgSetup,
+		LogEvent("cert"),
+		LogProcessor(src),
+	)
+}
+
+func (t *CertStore) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	t.RLock()
+	defer t.RUnlock()
+
+	if t.cert == nil {
+		return nil, ErrNoCert
+	}
+
+	res := &tls.Config{
+		Certificates: []tls.Certificate{*t.cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	if t.pool != nil {
+		res.ClientCAs = t.pool
+		res.ClientAuth = t.auth
+	}
+
+	return res, nil
+}
+
+func (t *CertStore) TLSConfig() *tls.Config {
+	res := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: t.configForClient,
+	}
+
+	return res
+}
+
+// PeerCert 校验通过的客户端证书
+func PeerCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// CertIdentity 优先取CN, 其次第一个DNS SAN
+func CertIdentity(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+
+	return ""
+}
+
+func ClientCertMiddleware() gin.HandlerFunc {
+	var h = func(c *gin.Context) {
+		cert := PeerCert(c.Request)
+		if cert != nil {
+			c.Set(TagClientCert, CertIdentity(cert))
+		}
+
+		c.Next()
+	}
+
+	return h
+}
+
+func GetClientCert(c *gin.Context) string {
+	return c.GetString(TagClientCert)
+}
+
+// RequireClientCert 用于ClientAuth为request时限定部分路由, allow为空时接受任意可信证书
+func RequireClientCert(allow ...string) gin.HandlerFunc {
+	var h = func(c *gin.Context) {
+		id := GetClientCert(c)
+		if id == "" || (len(allow) > 0 && !InStrList(allow, id)) {
+			c.JSON(
+				http.StatusUnauthorized,
+				NewFinalRsp("permission deny 0004", http.StatusUnauthorized),
+			)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+
+	return h
+}
@@ -32,22 +32,54 @@ type InvocationParam struct {
 	Trace   string            `json:"trace"`
 	Meta    map[string]string `json:"meta"`
 	Payload json.RawMessage   `json:"payload"`
+
+	ch   chan InvocationParam
+	wait time.Duration
 }
 
 func (t InvocationParam) Ctx() context.Context {
 	m := map[string]string{
-		TagSrc:    t.Src,
-		TagDst:    t.Dst,
-		TagTrace:  t.Trace,
-		TagSpan:   defaultSpanStr,
-		TagUser:   t.Meta[TagUser],
-		TagApp:    t.App,
-		TagMethod: t.Method,
+		TagSrc:     t.Src,
+		TagDst:     t.Dst,
+		TagTrace:   t.Trace,
+		TagSpan:    DeStrParam(t.Meta[TagSpan], defaultSpanStr),
+		TagUser:    t.Meta[TagUser],
+		TagTenant:  t.Meta[TagTenant],
+		TagRole:    t.Meta[TagRole],
+		TagTimeout: t.Meta[TagTimeout],
+		TagApp:     t.App,
+		TagMethod:  t.Method,
+		TagSign:    t.Meta[TagSign],
+		TagSignTs:  t.Meta[TagSignTs],
 	}
 
 	return NewIncomingContext(m)
 }
 
+// Sign 投递前由调用方签名, 接收方经DISP.Call校验
+func (t *InvocationParam) Sign() {
+	md := map[string]string{
+		TagTrace: t.Trace,
+		TagUser:  t.Meta[TagUser],
+		TagRole:  t.Meta[TagRole],
+	}
+
+	SignMd(md, t.App, t.Method)
+
+	t.Meta[TagSign] = md[TagSign]
+	t.Meta[TagSignTs] = md[TagSignTs]
+}
+
+// Key 同一trace下可能有多个异步调用, 以call区分
+func (t InvocationParam) Key() string {
+	call := t.Meta[tagAsyncCall]
+	if call == "" {
+		return t.Trace
+	}
+
+	return t.Trace + ":" + call
+}
+
 func (t InvocationParam) Invalid(raw ...string) bool {
 	if t.Trace == "" || t.Src == "" {
 		return true
@@ -86,11 +118,16 @@ func (t *InvocationParam) TimeoutRes(method string) {
 
 func (t *InvocationParam) Register(wait time.Duration) {
 	t.Src = INST()
-	cc.Register(t.Trace, wait)
+	t.ch = cc.Register(t.Key(), wait)
+	t.wait = wait
 }
 
 func (t InvocationParam) GetResponse(ctx context.Context) (rsp *InvocationParam, err error) {
-	return cc.GetResponse(ctx, t.Trace, t.Method)
+	if t.ch != nil {
+		return cc.Wait(ctx, t.ch, t.Key(), t.Method, t.wait)
+	}
+
+	return cc.GetResponse(ctx, t.Key(), t.Method)
 }
 
 func (t InvocationParam) Submit() {
@@ -112,7 +149,9 @@ func NewInvocationReq(ctx context.Context, src, dst string, req *Req) Invocation
 	}
 
 	meta := map[string]string{
-		TagUser: GetUser(ctx),
+		TagUser:   GetUser(ctx),
+		TagTenant: GetTenant(ctx),
+		TagRole:   GetStringFromContext(ctx, TagRole),
 	}
 
 	res := InvocationReq{
@@ -161,7 +200,7 @@ func IotReq(method string, obj interface{}) *Req {
 func NewReqFromGin(c *gin.Context, app, method string) *Req {
 	res := &Req{
 		App:    app,
-		Method: method,
+		Method: GinVersion(c, method),
 		Param:  GetBodyFromGin(c),
 	}
 
@@ -34,6 +34,10 @@ func NewMetaContext(ctx context.Context, md map[string]string) context.Context {
 	return metadata.NewContext(ctx, md)
 }
 
+func MergeMetaContext(ctx context.Context, md map[string]string) context.Context {
+	return metadata.MergeContext(ctx, md, true)
+}
+
 func MetaFromContext(ctx context.Context) (metadata.Metadata, bool) {
 	return metadata.FromContext(ctx)
 }
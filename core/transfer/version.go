@@ -0,0 +1,271 @@
+package transfer
+
+import (
+	"context"
+	. "mykit/core/dsp"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 版本化方法以 method@vN 注册, 未带版本即v1
+// 未带版本的请求命中DefaultVersion指定的版本, 未指定时命中已注册的最高版本
+// 请求的版本不存在时回退到不高于它的最新版本, 命中旧版本或已弃用的版本时告警
+
+const (
+	versionSep = "@v"
+)
+
+var (
+	// DeprecatedLogInterval 同一方法弃用告警的最小间隔
+	DeprecatedLogInterval = time.Minute
+)
+
+func VersionedMethod(method string, version int) string {
+	if version <= 1 {
+		return method
+	}
+
+	return method + versionSep + strconv.Itoa(version)
+}
+
+func ParseMethodVersion(raw string) (method string, version int) {
+	i := strings.LastIndex(raw, versionSep)
+	if i < 0 {
+		return raw, 1
+	}
+
+	v, err := strconv.Atoi(raw[i+len(versionSep):])
+	if err != nil || v < 1 {
+		return raw, 1
+	}
+
+	return raw[:i], v
+}
+
+// GinVersion Version头形如 2 或 v2, method已带版本时以method为准
+func GinVersion(c *gin.Context, method string) string {
+	if strings.Contains(method, versionSep) {
+		return method
+	}
+
+	raw := strings.TrimPrefix(strings.ToLower(c.GetHeader(HeadVersion)), "v")
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return method
+	}
+
+	return VersionedMethod(method, v)
+}
+
+// DispatchTarget 优先按 app@vN 注册的服务转发, 依次回退到低版本及app本身
+func DispatchTarget(app, method string) string {
+	routeLock.RLock()
+	defer routeLock.RUnlock()
+
+	_, v := ParseMethodVersion(method)
+	for ; v > 1; v-- {
+		target, ok := AppDispatch[VersionedMethod(app, v)]
+		if ok {
+			return target
+		}
+	}
+
+	return AppDispatch[app]
+}
+
+func (t *Lpc) AddVersion(version int, h ...Handler) {
+	for _, v := range h {
+		n := reflect.ValueOf(v).NumMethod()
+		for i := 0; i < n; i++ {
+			meta := t.parseMeta(1, v, i)
+			if meta.name == "" {
+				continue
+			}
+
+			meta.version = version
+			meta.name = VersionedMethod(meta.name, version)
+
+			t.Reg(meta)
+		}
+	}
+}
+
+func (t *Lpc) Deprecate(method ...string) {
+	t.RWMutex.Lock()
+	defer t.RWMutex.Unlock()
+
+	for _, v := range method {
+		meta, ok := t.f[v]
+		if ok {
+			meta.deprecated = true
+			t.f[v] = meta
+		}
+	}
+}
+
+// DefaultVersion 指定未带版本的请求使用的版本, version<1时恢复为最高版本
+func (t *Lpc) DefaultVersion(method string, version int) {
+	t.RWMutex.Lock()
+	defer t.RWMutex.Unlock()
+
+	if version < 1 {
+		delete(t.d, method)
+		return
+	}
+
+	t.d[method] = version
+}
+
+// Resolve 未带版本时取缺省版本, 精确匹配失败时回退到不高于请求版本的最新版本
+func (t *Lpc) Resolve(method string) (meta LpcMeta, ok bool) {
+	t.RWMutex.RLock()
+	defer t.RWMutex.RUnlock()
+
+	name, v := ParseMethodVersion(method)
+	if !strings.Contains(method, versionSep) {
+		v, ok = t.d[name]
+		if !ok {
+			v = t.v[name]
+		}
+	}
+
+	meta, ok = t.f[VersionedMethod(name, v)]
+	if ok {
+		return
+	}
+
+	for v--; v >= 1; v-- {
+		meta, ok = t.f[VersionedMethod(name, v)]
+		if ok {
+			return
+		}
+	}
+
+	return
+}
+
+// latest 注册或移除方法后重算其最高版本, 调用方持有写锁
+func (t *Lpc) latest(method string) {
+	name, _ := ParseMethodVersion(method)
+
+	top := 0
+	for k, v := range t.f {
+		n, _ := ParseMethodVersion(k)
+		if n == name && v.version > top {
+			top = v.version
+		}
+	}
+
+	if top == 0 {
+		delete(t.v, name)
+		return
+	}
+
+	t.v[name] = top
+}
+
+// Outdated 已弃用或存在更高版本
+func (t *Lpc) Outdated(meta LpcMeta) bool {
+	if meta.deprecated {
+		return true
+	}
+
+	name, _ := ParseMethodVersion(meta.name)
+
+	t.RWMutex.RLock()
+	defer t.RWMutex.RUnlock()
+
+	return t.v[name] > meta.version
+}
+
+// warnDue 每个方法在DeprecatedLogInterval内只告警一次
+func (t *Lpc) warnDue(method string) bool {
+	now := time.Now()
+
+	t.RWMutex.RLock()
+	last, ok := t.w[method]
+	t.RWMutex.RUnlock()
+
+	if ok && now.Sub(last) < DeprecatedLogInterval {
+		return false
+	}
+
+	t.RWMutex.Lock()
+	defer t.RWMutex.Unlock()
+
+	last, ok = t.w[method]
+	if ok && now.Sub(last) < DeprecatedLogInterval {
+		return false
+	}
+
+	t.w[method] = now
+
+	return true
+}
+
+// resolvedKey 记录DISP已解析出的实际版本, 避免Lpc再次按缺省版本解析
+type resolvedKey struct{}
+
+// lookup 已由DISP解析过的method精确匹配
+func (t *Lpc) lookup(ctx context.Context, method string) (meta LpcMeta, ok bool) {
+	if ctx.Value(resolvedKey{}) == method {
+		return t.Meta(method)
+	}
+
+	return t.Resolve(method)
+}
+
+// resolveReq 将method替换为实际命中的版本
+func (t *Lpc) resolveReq(ctx context.Context, req *Req) (context.Context, *Req) {
+	meta, ok := t.Resolve(req.GetMethod())
+	if !ok {
+		return context.WithValue(ctx, TagMethod, req.GetMethod()), req
+	}
+
+	if t.Outdated(meta) && t.warnDue(meta.name) {
+		Logger(ctx).Warn(meta.name,
+			LogEvent("deprecated"),
+			LogProcessor(t.app),
+			LogDetail(map[string]interface{}{
+				TagMethod:  req.GetMethod(),
+				TagVersion: meta.version,
+			}),
+		)
+	}
+
+	ctx = context.WithValue(ctx, TagMethod, meta.name)
+	ctx = context.WithValue(ctx, resolvedKey{}, meta.name)
+
+	if meta.name == req.GetMethod() {
+		return ctx, req
+	}
+
+	res := &Req{
+		App:    req.GetApp(),
+		Method: meta.name,
+		Param:  req.GetParam(),
+	}
+
+	return ctx, res
+}
+
+func (t *LpcDispatch) AddVersion(app string, version int, f ...Handler) {
+	t.getLpc(app).AddVersion(version, f...)
+}
+
+func (t *LpcDispatch) DefaultVersion(app, method string, version int) {
+	t.getLpc(app).DefaultVersion(method, version)
+}
+
+func (t *LpcDispatch) Deprecate(app string, method ...string) {
+	lpc, ok := t.Get(app)
+	if !ok {
+		return
+	}
+
+	lpc.Deprecate(method...)
+}
@@ -0,0 +1,551 @@
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	. "mykit/core/dsp"
+	. "mykit/core/types"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/websocket"
+)
+
+// websocket推送: 连接按tenant/user/scn索引, 推送经redis pub/sub扇出到所有网关实例
+// 指定了user的消息同时写入用户信箱, 客户端重连时以last=<id>补发错过的消息
+
+const (
+	ProtocolWs     = "ws"
+	wsChannel      = "lpc:ws:push"
+	wsSeqKey       = "lpc:ws:seq"
+	wsBoxPrefix    = "lpc:ws:box:"
+	wsPing         = "ping"
+	wsPong         = "pong"
+	wsEventMessage = "message"
+)
+
+var (
+	WsPingInterval = time.Second * 30
+	WsPongWait     = time.Second * 75
+	WsWriteWait    = time.Second * 10
+	WsSendBuffer   = 64
+	WsBacklog      = int64(100)
+	WsBacklogTTL   = time.Minute * 10
+	WsReadLimit    = int64(4096)
+
+	// WsOrigins 允许的跨域Origin, 如https://a.com; 同源与不带Origin的非浏览器客户端始终允许
+	WsOrigins []string
+)
+
+// WsPush 目标条件均为空时广播
+type WsPush struct {
+	Tenant string          `json:"tenant,optional"`
+	User   []string        `json:"user,optional"`
+	Scn    string          `json:"scn,optional"`
+	Event  string          `json:"event,optional"`
+	Data   json.RawMessage `json:"data,optional"`
+	Id     int64           `json:"id,optional"`
+	Ts     int64           `json:"ts,optional"`
+}
+
+// WsFrame 下发给客户端的帧
+type WsFrame struct {
+	Id    int64           `json:"id"`
+	Ts    int64           `json:"ts"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+func (t WsPush) Frame() []byte {
+	res := WsFrame{
+		Id:    t.Id,
+		Ts:    t.Ts,
+		Event: t.Event,
+		Data:  EnsureJsonByte(t.Data),
+	}
+
+	return MustJsonMarshal(res)
+}
+
+func wsUserKey(tenant, user string) string {
+	return tenant + ":" + user
+}
+
+type WsConn struct {
+	conn   *websocket.Conn
+	send   chan []byte
+	done   chan struct{}
+	once   sync.Once
+	remote string
+	Tenant string
+	User   string
+	Scn    string
+}
+
+func (t *WsConn) Protocol() string {
+	return ProtocolWs
+}
+
+func (t *WsConn) ID() ConnectionId {
+	return ConnectionId(uintptr(unsafe.Pointer(t)))
+}
+
+func (t *WsConn) RemoteAddr() string {
+	return t.remote
+}
+
+func (t *WsConn) Ping() error {
+	return t.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(WsWriteWait))
+}
+
+func (t *WsConn) Close() error {
+	t.once.Do(func() {
+		close(t.done)
+	})
+
+	return nil
+}
+
+// Send 发送队列满时断开慢连接, 客户端重连后从信箱补发
+func (t *WsConn) Send(ctx context.Context, raw []byte) error {
+	select {
+	case <-t.done:
+		return ErrOffline
+	default:
+	}
+
+	select {
+	case t.send <- raw:
+		return nil
+	default:
+		t.Close()
+		return ErrReachLimit
+	}
+}
+
+func (t *WsConn) match(msg WsPush) bool {
+	if msg.Tenant != "" && msg.Tenant != t.Tenant {
+		return false
+	}
+
+	if msg.Scn != "" && msg.Scn != t.Scn {
+		return false
+	}
+
+	return len(msg.User) == 0 || InStrList(msg.User, t.User)
+}
+
+func (t *WsConn) readLoop() {
+	defer t.Close()
+
+	t.conn.SetReadLimit(WsReadLimit)
+	t.conn.SetReadDeadline(time.Now().Add(WsPongWait))
+	t.conn.SetPongHandler(func(string) error {
+		return t.conn.SetReadDeadline(time.Now().Add(WsPongWait))
+	})
+
+	for {
+		_, raw, err := t.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		t.conn.SetReadDeadline(time.Now().Add(WsPongWait))
+
+		//应用层心跳, 供无法发送ping帧的客户端使用
+		if string(raw) == wsPing {
+			t.Send(Ctx, []byte(wsPong))
+		}
+	}
+}
+
+func (t *WsConn) writeLoop() {
+	ticker := time.NewTicker(WsPingInterval)
+
+	defer func() {
+		ticker.Stop()
+		t.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(WsWriteWait))
+		t.conn.Close()
+	}()
+
+	for {
+		select {
+		case <-t.done:
+			return
+
+		case raw := <-t.send:
+			t.conn.SetWriteDeadline(time.Now().Add(WsWriteWait))
+			err := t.conn.WriteMessage(websocket.TextMessage, raw)
+			if err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			if t.Ping() != nil {
+				return
+			}
+		}
+	}
+}
+
+type WsStat struct {
+	Conn int `json:"conn"`
+	User int `json:"user"`
+}
+
+type WsHub struct {
+	sync.RWMutex
+	conns    map[ConnectionId]*WsConn
+	users    map[string]map[ConnectionId]*WsConn
+	cli      redis.Cmdable
+	seq      int64
+	upgrader websocket.Upgrader
+}
+
+// NewWsHub cli为nil时只推送本实例连接, 且不支持重连补发
+func NewWsHub(cli redis.Cmdable) *WsHub {
+	res := &WsHub{
+		conns: map[ConnectionId]*WsConn{},
+		users: map[string]map[ConnectionId]*WsConn{},
+		cli:   cli,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     wsCheckOrigin,
+		},
+	}
+
+	return res
+}
+
+func wsCheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	return InStrList(WsOrigins, origin)
+}
+
+func (t *WsHub) add(c *WsConn) {
+	k := wsUserKey(c.Tenant, c.User)
+
+	t.Lock()
+	t.conns[c.ID()] = c
+	m, ok := t.users[k]
+	if !ok {
+		m = map[ConnectionId]*WsConn{}
+		t.users[k] = m
+	}
+	m[c.ID()] = c
+	t.Unlock()
+}
+
+func (t *WsHub) remove(c *WsConn) {
+	k := wsUserKey(c.Tenant, c.User)
+
+	t.Lock()
+	delete(t.conns, c.ID())
+	m := t.users[k]
+	delete(m, c.ID())
+	if len(m) == 0 {
+		delete(t.users, k)
+	}
+	t.Unlock()
+}
+
+func (t *WsHub) Stat() WsStat {
+	t.RLock()
+	defer t.RUnlock()
+
+	res := WsStat{
+		Conn: len(t.conns),
+		User: len(t.users),
+	}
+
+	return res
+}
+
+func (t *WsHub) Online(tenant, user string) int {
+	t.RLock()
+	defer t.RUnlock()
+
+	return len(t.users[wsUserKey(tenant, user)])
+}
+
+// Deliver 推送给本实例匹配的连接, 返回送达的连接数
+func (t *WsHub) Deliver(msg WsPush) int {
+	targets := []*WsConn{}
+
+	t.RLock()
+	if len(msg.User) > 0 && msg.Tenant != "" {
+		for _, v := range msg.User {
+			for _, c := range t.users[wsUserKey(msg.Tenant, v)] {
+				if c.match(msg) {
+					targets = append(targets, c)
+				}
+			}
+		}
+	} else {
+		for _, c := range t.conns {
+			if c.match(msg) {
+				targets = append(targets, c)
+			}
+		}
+	}
+	t.RUnlock()
+
+	raw := msg.Frame()
+	n := 0
+	for _, v := range targets {
+		if v.Send(Ctx, raw) == nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (t *WsHub) nextId(ctx context.Context) int64 {
+	if t.cli != nil {
+		id, err := t.cli.Incr(ctx, wsSeqKey).Result()
+		if err == nil {
+			return id
+		}
+	}
+
+	return atomic.AddInt64(&t.seq, 1)
+}
+
+// Push 分配id, 写入用户信箱后扇出; 未配置redis时仅本实例投递
+func (t *WsHub) Push(ctx context.Context, msg WsPush) (int64, error) {
+	if msg.Event == "" {
+		msg.Event = wsEventMessage
+	}
+
+	msg.Id = t.nextId(ctx)
+	msg.Ts = time.Now().UnixMilli()
+
+	if t.cli == nil {
+		t.Deliver(msg)
+		return msg.Id, nil
+	}
+
+	raw := MustJsonMarshal(msg)
+
+	pipe := t.cli.TxPipeline()
+	for _, v := range msg.User {
+		k := wsBoxPrefix + wsUserKey(msg.Tenant, v)
+		pipe.LPush(ctx, k, raw)
+		pipe.LTrim(ctx, k, 0, WsBacklog-1)
+		pipe.Expire(ctx, k, WsBacklogTTL)
+	}
+	pipe.Publish(ctx, wsChannel, raw)
+
+	_, err := pipe.Exec(ctx)
+
+	return msg.Id, err
+}
+
+// Send 实现MessagePusher, 非WsPush消息推送给ctx中的用户
+func (t *WsHub) Send(ctx context.Context, msg interface{}) error {
+	res := WsPush{
+		Tenant: GetTenant(ctx),
+		User:   []string{GetUser(ctx)},
+		Data:   JsonRawMessage(msg),
+	}
+
+	switch v := msg.(type) {
+	case WsPush:
+		res = v
+	case *WsPush:
+		res = *v
+	}
+
+	_, err := t.Push(ctx, res)
+
+	return err
+}
+
+type wsSubscriber interface {
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// Run 订阅扇出频道, 阻塞至ctx结束后断开全部连接
+func (t *WsHub) Run(ctx context.Context) {
+	defer t.Close()
+
+	cli, ok := t.cli.(wsSubscriber)
+	if !ok {
+		<-ctx.Done()
+		return
+	}
+
+	sub := cli.Subscribe(ctx, wsChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case v, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			var msg WsPush
+			err := UnmarshalJson(StringToBytes(v.Payload), &msg)
+			if err != nil {
+				continue
+			}
+
+			t.Deliver(msg)
+		}
+	}
+}
+
+// resume 补发信箱中id大于last的消息, 未指定tenant推送的消息存于":user"信箱
+// 与实时推送可能重复, 客户端按id去重
+func (t *WsHub) resume(c *WsConn, last int64) {
+	if t.cli == nil || last <= 0 {
+		return
+	}
+
+	keys := []string{wsBoxPrefix + wsUserKey(c.Tenant, c.User)}
+	if c.Tenant != "" {
+		keys = append(keys, wsBoxPrefix+wsUserKey("", c.User))
+	}
+
+	all := []WsPush{}
+	for _, k := range keys {
+		raw, err := t.cli.LRange(Ctx, k, 0, WsBacklog-1).Result()
+		if err != nil {
+			continue
+		}
+
+		for _, v := range raw {
+			var msg WsPush
+			err = UnmarshalJson(StringToBytes(v), &msg)
+			if err != nil || msg.Id <= last || !c.match(msg) {
+				continue
+			}
+
+			all = append(all, msg)
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Id < all[j].Id
+	})
+
+	for _, v := range all {
+		c.Send(Ctx, v.Frame())
+	}
+}
+
+func (t *WsHub) Close() {
+	t.RLock()
+	all := []*WsConn{}
+	for _, v := range t.conns {
+		all = append(all, v)
+	}
+	t.RUnlock()
+
+	for _, v := range all {
+		v.Close()
+	}
+}
+
+// Handler 挂在鉴权中间件之后, 用户取自gin context, 与http接口一致
+func (t *WsHub) Handler() func(c *gin.Context) {
+	var h = func(c *gin.Context) {
+		user := GetUserAccount(c)
+		if user == "" {
+			c.JSON(
+				http.StatusUnauthorized,
+				NewFinalRsp("permission deny 0003", http.StatusUnauthorized),
+			)
+			c.Abort()
+			return
+		}
+
+		conn, err := t.upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			return
+		}
+
+		last, _ := strconv.ParseInt(c.Query("last"), 10, 64)
+
+		//补发最多两个信箱的消息, 不占用实时推送的缓冲
+		size := WsSendBuffer
+		if last > 0 && t.cli != nil {
+			size += int(WsBacklog) * 2
+		}
+
+		wc := &WsConn{
+			conn:   conn,
+			send:   make(chan []byte, size),
+			done:   make(chan struct{}),
+			remote: c.GetString(TagRemote),
+			Tenant: c.GetString(TagTenant),
+			User:   user,
+			Scn:    GetScn(c),
+		}
+
+		//浏览器无法自定义header, scn可由query指定
+		if wc.Scn == "" {
+			wc.Scn = c.Query(TagScn)
+		}
+
+		t.add(wc)
+
+		Logger(c).Info(LogMsgWebSocket,
+			LogEvent("connect"),
+			LogUser(user),
+			LogFrom(wc.remote),
+		)
+
+		go wc.writeLoop()
+		t.resume(wc, last)
+		wc.readLoop()
+
+		<-wc.done
+		t.remove(wc)
+
+		Logger(c).Info(LogMsgWebSocket,
+			LogEvent("disconnect"),
+			LogUser(user),
+			LogFrom(wc.remote),
+		)
+	}
+
+	return h
+}
+
+func (t *WsHub) StatHandler() func(c *gin.Context) {
+	var h = func(c *gin.Context) {
+		SuccessFinalRsp2(t.Stat()).Send(c)
+	}
+
+	return h
+}
@@ -124,4 +124,6 @@ var (
 	CtxKind        = reflect.TypeOf(Ctx).Kind()
 	ErrKind        = reflect.TypeOf(ErrInvalidParam).Kind()
 	NormalCtxInput = []reflect.Value{reflect.ValueOf(Ctx)}
+	CtxType        = reflect.TypeOf((*context.Context)(nil)).Elem()
+	ErrType        = reflect.TypeOf((*error)(nil)).Elem()
 )
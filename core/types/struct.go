@@ -209,20 +209,13 @@ func IsSlicePtrKind(raw reflect.Type) bool {
 	return raw.Elem().Kind() == reflect.Slice
 }
 
+// IsContextKind 入参须声明为context.Context, 按Kind比较在Background不再是指针后失效
 func IsContextKind(raw reflect.Type) bool {
-	if raw.Kind() == CtxKind {
-		return true
-	}
-
-	return reflect.TypeOf(raw).Kind() == CtxKind
+	return raw == CtxType
 }
 
 func IsErrorKind(raw reflect.Type) bool {
-	if raw.Kind() == ErrKind {
-		return true
-	}
-
-	return reflect.TypeOf(raw).Kind() == ErrKind
+	return raw == ErrType
 }
 
 func SumStructInt32Filed(raw interface{}) int32 {